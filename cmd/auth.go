@@ -0,0 +1,59 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+
+	"go.uber.org/zap"
+	"k8s.io/client-go/kubernetes"
+	ctrl "sigs.k8s.io/controller-runtime"
+
+	"juicefs-mcp/pkg/auth"
+)
+
+// initAuth builds the authenticator and the TLS config of the network transports.
+// The TLS config is nil if TLS is not configured.
+func initAuth(log *zap.SugaredLogger) (*auth.Authenticator, *tls.Config, error) {
+	authTokenFile, authTokenSecret := cfg.Auth.TokenFile, cfg.Auth.TokenSecret
+	tlsCert, tlsKey, tlsClientCA := cfg.Auth.TLSCert, cfg.Auth.TLSKey, cfg.Auth.TLSClientCA
+	authenticator := auth.NewAuthenticator()
+	if authTokenFile != "" {
+		if err := authenticator.LoadTokenFile(authTokenFile); err != nil {
+			return nil, nil, err
+		}
+	}
+	if authTokenSecret != "" {
+		config, err := ctrl.GetConfig()
+		if err != nil {
+			return nil, nil, fmt.Errorf("get kubeconfig error: %w", err)
+		}
+		clientSet, err := kubernetes.NewForConfig(config)
+		if err != nil {
+			return nil, nil, fmt.Errorf("create kubernetes client error: %w", err)
+		}
+		if err := authenticator.LoadTokenSecret(context.Background(), clientSet, authTokenSecret); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	if tlsCert == "" && tlsKey == "" {
+		if tlsClientCA != "" {
+			return nil, nil, fmt.Errorf("tls-client-ca requires tls-cert and tls-key")
+		}
+		return authenticator, nil, nil
+	}
+	reloader, err := auth.NewCertReloader(tlsCert, tlsKey)
+	if err != nil {
+		return nil, nil, err
+	}
+	tlsConfig, err := auth.NewTLSConfig(reloader, tlsClientCA)
+	if err != nil {
+		return nil, nil, err
+	}
+	if tlsClientCA != "" {
+		authenticator.EnableClientCert(cfg.Auth.TLSAllowedCNs)
+	}
+	log.Infow("TLS enabled", "cert", tlsCert, "mtls", tlsClientCA != "")
+	return authenticator, tlsConfig, nil
+}
@@ -3,6 +3,11 @@ package main
 import (
 	"context"
 	"flag"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"time"
 
 	"github.com/mark3labs/mcp-go/server"
 	"go.uber.org/zap"
@@ -10,94 +15,314 @@ import (
 
 	ctrl "sigs.k8s.io/controller-runtime"
 
+	"juicefs-mcp/pkg/audit"
+	"juicefs-mcp/pkg/auth"
+	"juicefs-mcp/pkg/config"
 	"juicefs-mcp/pkg/csi"
 	"juicefs-mcp/pkg/juicefs"
+	"juicefs-mcp/pkg/metrics"
 	"juicefs-mcp/pkg/tools"
-	"juicefs-mcp/pkg/utils"
 	"juicefs-mcp/pkg/utils/logger"
 )
 
 const (
 	version = "0.1.0"
+
+	// configCheckInterval is how often the config and policy files are checked for changes.
+	configCheckInterval = 5 * time.Second
 )
 
 var (
+	configFile   string
 	transport    string
 	sseUrl       string
+	baseUrl      string
+	httpPath     string
 	debug        bool
 	sysNamespace string
 	handlerName  string
-)
+	binPath      string
+	edition      string
 
-var JuiceMCPServer = server.NewMCPServer(
-	"juicefs-mcp-server",
-	version,
+	authTokenFile   string
+	authTokenSecret string
+	tlsCert         string
+	tlsKey          string
+	tlsClientCA     string
+	tlsAllowedCNs   string
+	policyFile      string
+
+	auditLog           string
+	auditLogMaxSize    int
+	auditLogMaxBackups int
+	metricsAddr        string
+	dataDir            string
+	rulesDir           string
 )
 
+// cfg is the config loaded from configFile with flags applied.
+var cfg *config.Config
+
+// liveCfg is the config as last reloaded, while cfg stays the one the server started with.
+var liveCfg atomic.Pointer[config.Config]
+
+var JuiceMCPServer *server.MCPServer
+
 func init() {
-	flag.StringVar(&transport, "t", "sse", "transport protocol")
-	flag.StringVar(&sseUrl, "sseurl", "0.0.0.0:8088", "sse url")
+	defaults := config.Default()
+	flag.StringVar(&configFile, "config", "", "YAML config file, flags override its values")
+	flag.StringVar(&transport, "t", defaults.Transport, "transport protocol, stdio, sse or http")
+	flag.StringVar(&sseUrl, "sseurl", defaults.Listen, "listen address of sse and http transport")
+	flag.StringVar(&baseUrl, "baseurl", "", "public base url of sse and http transport, default http://<sseurl>")
+	flag.StringVar(&httpPath, "httppath", defaults.HTTPPath, "endpoint path of http transport")
 	flag.BoolVar(&debug, "debug", false, "debug mode")
-	flag.StringVar(&sysNamespace, "sysnamespace", "kube-system", "namespace of JuiceFS CSI driver")
-	flag.StringVar(&handlerName, "handler", "csi", "handler kind")
+	flag.StringVar(&sysNamespace, "sysnamespace", defaults.CSI.SysNamespace, "namespace of JuiceFS CSI driver")
+	flag.StringVar(&binPath, "juicefs-bin", defaults.JuiceFS.BinPath, "path of juicefs binary")
+	flag.StringVar(&edition, "juicefs-edition", defaults.JuiceFS.Edition, "edition of juicefs, ce or ee")
+	flag.StringVar(&rulesDir, "rules-dir", "", "directory of YAML tuning advisor rules, merged over the embedded rules by rule id")
+	flag.StringVar(&authTokenFile, "auth-token-file", "", "file of bearer tokens, one <name>:<token> per line")
+	flag.StringVar(&authTokenSecret, "auth-token-secret", "", "Kubernetes Secret <namespace>/<name> of bearer tokens, key is client name and value is token")
+	flag.StringVar(&tlsCert, "tls-cert", "", "TLS certificate file, reloaded when changed")
+	flag.StringVar(&tlsKey, "tls-key", "", "TLS key file, reloaded when changed")
+	flag.StringVar(&tlsClientCA, "tls-client-ca", "", "CA file to verify client certificates (mTLS)")
+	flag.StringVar(&tlsAllowedCNs, "tls-allowed-cns", "", "comma separated CNs of client certificates allowed, empty allows all verified")
+	flag.StringVar(&policyFile, "policy", "", "YAML policy of the tiers and tools each client may call, default allows all")
+	flag.StringVar(&auditLog, "audit-log", "", "audit log of tool calls, a file path, stdout or stderr, empty disables it")
+	flag.IntVar(&auditLogMaxSize, "audit-log-max-size", defaults.Audit.MaxSizeMB, "max size in MiB of the audit log file before rotated")
+	flag.IntVar(&auditLogMaxBackups, "audit-log-max-backups", defaults.Audit.MaxBackups, "max number of rotated audit log files kept")
+	flag.StringVar(&metricsAddr, "metrics-addr", defaults.MetricsAddr, "listen address of the /metrics endpoint, empty disables it")
+	flag.StringVar(&dataDir, "data-dir", defaults.DataDir, "directory of the server state like the bench history, empty disables it")
+	flag.StringVar(&handlerName, "handler", strings.Join(defaults.Handlers, ","), "handler kinds, comma separated list of csi, juicefs or all")
+}
+
+// flagOverrides applies the value of each flag to the config, for the flags set on the command line.
+var flagOverrides = map[string]func(c *config.Config){
+	"t":                     func(c *config.Config) { c.Transport = transport },
+	"sseurl":                func(c *config.Config) { c.Listen = sseUrl },
+	"baseurl":               func(c *config.Config) { c.BaseURL = baseUrl },
+	"httppath":              func(c *config.Config) { c.HTTPPath = httpPath },
+	"debug":                 func(c *config.Config) { c.LogLevel = map[bool]string{true: "debug", false: "info"}[debug] },
+	"sysnamespace":          func(c *config.Config) { c.CSI.SysNamespace = sysNamespace },
+	"juicefs-bin":           func(c *config.Config) { c.JuiceFS.BinPath = binPath },
+	"juicefs-edition":       func(c *config.Config) { c.JuiceFS.Edition = edition },
+	"rules-dir":             func(c *config.Config) { c.JuiceFS.RulesDir = rulesDir },
+	"auth-token-file":       func(c *config.Config) { c.Auth.TokenFile = authTokenFile },
+	"auth-token-secret":     func(c *config.Config) { c.Auth.TokenSecret = authTokenSecret },
+	"tls-cert":              func(c *config.Config) { c.Auth.TLSCert = tlsCert },
+	"tls-key":               func(c *config.Config) { c.Auth.TLSKey = tlsKey },
+	"tls-client-ca":         func(c *config.Config) { c.Auth.TLSClientCA = tlsClientCA },
+	"tls-allowed-cns":       func(c *config.Config) { c.Auth.TLSAllowedCNs = strings.Split(tlsAllowedCNs, ",") },
+	"policy":                func(c *config.Config) { c.Auth.PolicyFile = policyFile },
+	"audit-log":             func(c *config.Config) { c.Audit.Path = auditLog },
+	"audit-log-max-size":    func(c *config.Config) { c.Audit.MaxSizeMB = auditLogMaxSize },
+	"audit-log-max-backups": func(c *config.Config) { c.Audit.MaxBackups = auditLogMaxBackups },
+	"metrics-addr":          func(c *config.Config) { c.MetricsAddr = metricsAddr },
+	"data-dir":              func(c *config.Config) { c.DataDir = dataDir },
+	"handler":               func(c *config.Config) { c.Handlers = strings.Split(handlerName, ",") },
+}
+
+// loadConfig loads the config file and applies the flags set on the command line over it.
+func loadConfig() (*config.Config, error) {
+	c, err := config.Load(configFile)
+	if err != nil {
+		return nil, err
+	}
+	flag.Visit(func(f *flag.Flag) {
+		if override, ok := flagOverrides[f.Name]; ok {
+			override(c)
+		}
+	})
+	return c, c.Validate()
+}
+
+func loadPolicy(path string) (*auth.Policy, error) {
+	if path == "" {
+		return auth.AllowAllPolicy, nil
+	}
+	return auth.LoadPolicy(path)
+}
+
+// reloadConfig applies the fields of the config that are safe to change at runtime.
+func reloadConfig(log *zap.SugaredLogger, authorizer *auth.Authorizer, limiter *tools.Limiter) {
+	newCfg, err := loadConfig()
+	if err != nil {
+		log.Errorw("Failed to reload config, keep the current one", "error", err)
+		return
+	}
+	policy, err := loadPolicy(newCfg.Auth.PolicyFile)
+	if err != nil {
+		log.Errorw("Failed to reload policy, keep the current one", "error", err)
+		return
+	}
+	if err := logger.SetLevel(newCfg.LogLevel); err != nil {
+		log.Errorw("Invalid log level, keep the current one", "level", newCfg.LogLevel, "error", err)
+	}
+	limiter.SetLimits(newCfg.Limits.MaxOutputBytes, newCfg.Limits.ToolTimeout)
+	authorizer.SetPolicy(policy)
+	liveCfg.Store(newCfg)
+	if cfg.RestartRequired(newCfg) {
+		log.Warnw("Config changed in fields that take effect on restart only")
+	}
+	log.Infow("Config reloaded", "logLevel", newCfg.LogLevel, "limits", newCfg.Limits, "policy", newCfg.Auth.PolicyFile)
+}
+
+// handlerInits starts the handler of each kind and registers its toolset.
+var handlerInits = map[string]func(log *zap.SugaredLogger) error{
+	csi.Namespace:     initCSIHandler,
+	juicefs.Namespace: initJuiceFSHandler,
+}
+
+func initJuiceFSHandler(log *zap.SugaredLogger) error {
+	log.Infow("init juicefs handler")
+	juicefsHandler := juicefs.NewJuiceFSHandler(cfg.JuiceFS.BinPath, cfg.JuiceFS.Edition, cfg.Timeouts.Command, cfg.DataDir, cfg.JuiceFS.RulesDir)
+	if err := juicefsHandler.ProbeVersion(context.Background()); err != nil {
+		log.Warnw("probe juicefs binary error, tools running it are disabled", "binPath", cfg.JuiceFS.BinPath, "error", err)
+	}
+	juicefs.RegisterJuiceFSTools(juicefsHandler)
+	return nil
+}
+
+func initCSIHandler(log *zap.SugaredLogger) error {
+	config, err := ctrl.GetConfig()
+	if err != nil {
+		return fmt.Errorf("get kubeconfig error: %w", err)
+	}
+	config.Wrap(metrics.WrapKubeTransport)
+	clientSet, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		return fmt.Errorf("create kubernetes client error: %w", err)
+	}
+	log.Infow("init csi handler")
+	csiHandler := csi.NewCSIHandler(cfg.CSI.SysNamespace, clientSet)
+	csi.RegisterJuiceCSITools(csiHandler)
+	return nil
+}
+
+// parseHandlerNames parses the handler kinds, "all" stands for every kind.
+func parseHandlerNames(names []string) []string {
+	results := []string{}
+	seen := map[string]bool{}
+	for _, name := range names {
+		name = strings.TrimSpace(name)
+		if name == "all" {
+			return []string{csi.Namespace, juicefs.Namespace}
+		}
+		if name == "" || seen[name] {
+			continue
+		}
+		seen[name] = true
+		results = append(results, name)
+	}
+	return results
 }
 
 func initTools(log *zap.SugaredLogger) {
-	switch handlerName {
-	case "juicefs":
-		// Register JuiceFS tools
-		log.Infow("init juicefs handler")
-		juicefsHandler := juicefs.NewJuiceFSHandler()
-		juicefs.RegisterJuiceFSTools(juicefsHandler)
-	case "csi":
-		// Register JuiceFS CSI tools
-		config := ctrl.GetConfigOrDie()
-		clientSet, err := kubernetes.NewForConfig(config)
-		if err != nil {
-			panic(err)
+	started := 0
+	for _, name := range parseHandlerNames(cfg.Handlers) {
+		initHandler, ok := handlerInits[name]
+		if !ok {
+			log.Warnw("unknown handler, skip it", "handler", name)
+			continue
+		}
+		if err := initHandler(log); err != nil {
+			log.Warnw("failed to init handler, skip it", "handler", name, "error", err)
+			continue
 		}
-		log.Infow("init csi handler")
-		csiHandler := csi.NewCSIHandler(sysNamespace, clientSet)
-		csi.RegisterJuiceCSITools(csiHandler)
+		started++
+	}
+	if started == 0 {
+		log.Fatalw("no handler started", "handlers", cfg.Handlers)
 	}
 
 	for _, tool := range tools.ToolRegistry {
 		JuiceMCPServer.AddTool(tool.Tool, tool.Handler)
 	}
+	for _, resource := range tools.ResourceRegistry {
+		JuiceMCPServer.AddResource(resource.Resource, resource.Handler)
+	}
+	for _, template := range tools.ResourceTemplateRegistry {
+		JuiceMCPServer.AddResourceTemplate(template.Template, template.Handler)
+	}
+	JuiceMCPServer.AddPrompts(tools.PromptRegistry...)
+}
+
+// serveMetrics serves /metrics on its own listen address in background.
+func serveMetrics(log *zap.SugaredLogger) {
+	if cfg.MetricsAddr == "" {
+		return
+	}
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", metrics.Handler())
+	go func() {
+		log.Infow("Metrics server start", "address", cfg.MetricsAddr)
+		if err := http.ListenAndServe(cfg.MetricsAddr, mux); err != nil {
+			log.Errorw("Failed to serve metrics", "error", err)
+		}
+	}()
 }
 
 func main() {
 	flag.Parse()
 	logger.InitLogger()
-	logger.SetDebug(debug)
 	defer logger.Sync()
 	log := logger.NewLogger("main")
 
+	var err error
+	if cfg, err = loadConfig(); err != nil {
+		log.Fatalw("Failed to load config", "error", err)
+	}
+	liveCfg.Store(cfg)
+	if err := logger.SetLevel(cfg.LogLevel); err != nil {
+		log.Fatalw("Invalid log level", "level", cfg.LogLevel, "error", err)
+	}
+
+	policy, err := loadPolicy(cfg.Auth.PolicyFile)
+	if err != nil {
+		log.Fatalw("Failed to load policy", "error", err)
+	}
+	authorizer := auth.NewAuthorizer(policy)
+	limiter := tools.NewLimiter(cfg.Limits.MaxOutputBytes, cfg.Limits.ToolTimeout)
+	canceller := tools.NewCanceller()
+	opts := []server.ServerOption{server.WithToolFilter(authorizer.FilterTools), server.WithHooks(canceller.Hooks())}
+	if cfg.Audit.Path != "" {
+		if cfg.Transport == "stdio" && (cfg.Audit.Path == "stdout" || cfg.Audit.Path == "-") {
+			log.Fatalw("Audit log can't go to stdout, which carries the JSON-RPC messages of stdio transport, use stderr or a file")
+		}
+		sink, err := audit.NewSink(cfg.Audit.Path, cfg.Audit.MaxSizeMB, cfg.Audit.MaxBackups)
+		if err != nil {
+			log.Fatalw("Failed to open audit log", "error", err)
+		}
+		// audit is the outermost middleware so that unauthorized calls are recorded too
+		opts = append(opts, server.WithToolHandlerMiddleware(audit.NewAuditor(sink).Middleware))
+	}
+	opts = append(opts,
+		server.WithToolHandlerMiddleware(metrics.ToolMiddleware),
+		server.WithToolHandlerMiddleware(authorizer.Middleware),
+		server.WithToolHandlerMiddleware(limiter.Middleware),
+		server.WithToolHandlerMiddleware(canceller.Middleware),
+	)
+	JuiceMCPServer = server.NewMCPServer("juicefs-mcp-server", version, opts...)
+	JuiceMCPServer.AddNotificationHandler(tools.MethodNotificationCancelled, canceller.HandleCancelled)
+
 	initTools(log)
+	serveMetrics(log)
 
-	switch transport {
+	if configFile != "" || cfg.Auth.PolicyFile != "" {
+		go config.Watch(context.Background(), configCheckInterval,
+			// the policy file may be changed by the config file, the one of the live config is watched
+			func() []string { return []string{configFile, liveCfg.Load().Auth.PolicyFile} },
+			func() { reloadConfig(log, authorizer, limiter) },
+		)
+	}
+
+	switch cfg.Transport {
 	case "stdio":
 		if err := server.ServeStdio(JuiceMCPServer); err != nil {
 			log.Fatalf("Server error: %v", err)
 		}
 	case "sse":
-		sseServer := server.NewSSEServer(JuiceMCPServer, server.WithBaseURL(sseUrl))
-
-		stop := utils.HandleTerminalSignal()
-		go func() {
-			<-stop
-			ctx, cancel := context.WithTimeout(context.Background(), 5)
-			defer cancel()
-			if err := sseServer.Shutdown(ctx); err != nil {
-				log.Fatalw("Failed to gracefully shutdown sse", "error", err)
-			}
-		}()
-
-		log.Infow("SSE server start", "address", sseUrl)
-		if err := sseServer.Start(sseUrl); err != nil {
-			log.Fatalw("Failed to start sse", "error", err)
-		}
-	default:
-		log.Fatalw("Invalid transport type. Must be 'stdio' or 'sse'.", "transport", transport)
+		serveSSE(log)
+	case "http":
+		serveStreamableHTTP(log)
 	}
 }
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/mark3labs/mcp-go/server"
+	"go.uber.org/zap"
+
+	mcptransport "juicefs-mcp/pkg/transport"
+	"juicefs-mcp/pkg/utils"
+)
+
+const (
+	heartbeatInterval = 30 * time.Second
+	// eventHistorySize is the number of events kept per stream for stream resumption.
+	eventHistorySize = 100
+)
+
+func publicBaseUrl() string {
+	if cfg.BaseURL != "" {
+		return cfg.BaseURL
+	}
+	if cfg.Auth.TLSCert != "" {
+		return "https://" + cfg.Listen
+	}
+	return "http://" + cfg.Listen
+}
+
+// shutdownOnSignal gracefully shuts down the server once a terminal signal is received.
+func shutdownOnSignal(log *zap.SugaredLogger, shutdown func(ctx context.Context) error) {
+	stop := utils.HandleTerminalSignal()
+	go func() {
+		<-stop
+		ctx, cancel := context.WithTimeout(context.Background(), cfg.Timeouts.Shutdown)
+		defer cancel()
+		if err := shutdown(ctx); err != nil {
+			log.Fatalw("Failed to gracefully shutdown", "transport", cfg.Transport, "error", err)
+		}
+	}()
+}
+
+// setupHTTPServer makes httpServer serve handler on the listen address, with authentication
+// in front of handler and TLS if configured.
+func setupHTTPServer(log *zap.SugaredLogger, httpServer *http.Server, handler http.Handler) {
+	authenticator, tlsConfig, err := initAuth(log)
+	if err != nil {
+		log.Fatalw("Failed to init authentication", "error", err)
+	}
+	if authenticator.Enabled() {
+		handler = authenticator.Middleware(handler)
+	} else {
+		log.Warnw("Authentication disabled, anyone who can reach the server can call all tools", "address", cfg.Listen)
+	}
+	httpServer.Addr = cfg.Listen
+	httpServer.Handler = handler
+	httpServer.TLSConfig = tlsConfig
+}
+
+// listenAndServe serves httpServer until it is shutdown.
+func listenAndServe(httpServer *http.Server) error {
+	var err error
+	if httpServer.TLSConfig != nil {
+		err = httpServer.ListenAndServeTLS("", "")
+	} else {
+		err = httpServer.ListenAndServe()
+	}
+	if errors.Is(err, http.ErrServerClosed) {
+		return nil
+	}
+	return err
+}
+
+func serveSSE(log *zap.SugaredLogger) {
+	httpServer := &http.Server{}
+	sseServer := server.NewSSEServer(JuiceMCPServer,
+		server.WithBaseURL(publicBaseUrl()),
+		server.WithHTTPServer(httpServer),
+	)
+	setupHTTPServer(log, httpServer, sseServer)
+	shutdownOnSignal(log, sseServer.Shutdown)
+
+	log.Infow("SSE server start", "address", cfg.Listen, "baseUrl", publicBaseUrl())
+	if err := listenAndServe(httpServer); err != nil {
+		log.Fatalw("Failed to start sse", "error", err)
+	}
+}
+
+func serveStreamableHTTP(log *zap.SugaredLogger) {
+	httpServer := &http.Server{}
+	streamableServer := server.NewStreamableHTTPServer(JuiceMCPServer,
+		server.WithEndpointPath(cfg.HTTPPath),
+		server.WithHeartbeatInterval(heartbeatInterval),
+		server.WithStreamableHTTPServer(httpServer),
+	)
+	mux := http.NewServeMux()
+	mux.Handle(cfg.HTTPPath, mcptransport.NewResumableHandler(streamableServer, eventHistorySize))
+	setupHTTPServer(log, httpServer, mux)
+	shutdownOnSignal(log, streamableServer.Shutdown)
+
+	log.Infow("HTTP server start", "address", cfg.Listen, "endpoint", publicBaseUrl()+cfg.HTTPPath)
+	if err := listenAndServe(httpServer); err != nil {
+		log.Fatalw("Failed to start http", "error", err)
+	}
+}
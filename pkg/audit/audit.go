@@ -0,0 +1,131 @@
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+
+	"juicefs-mcp/pkg/auth"
+	"juicefs-mcp/pkg/utils"
+)
+
+// Record is the audit record of one tool call.
+type Record struct {
+	Time        time.Time      `json:"time"`
+	Client      string         `json:"client"`
+	AuthMethod  string         `json:"authMethod,omitempty"`
+	Session     string         `json:"session,omitempty"`
+	ClientInfo  string         `json:"clientInfo,omitempty"`
+	Tool        string         `json:"tool"`
+	Arguments   map[string]any `json:"arguments,omitempty"`
+	DurationMs  int64          `json:"durationMs"`
+	ResultBytes int            `json:"resultBytes"`
+	IsError     bool           `json:"isError"`
+	Error       string         `json:"error,omitempty"`
+}
+
+// Auditor writes one JSON record per tool call to its sink, independent of the log level.
+type Auditor struct {
+	lock sync.Mutex
+	enc  *json.Encoder
+}
+
+func NewAuditor(sink io.Writer) *Auditor {
+	return &Auditor{enc: json.NewEncoder(sink)}
+}
+
+func (a *Auditor) write(record Record) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	_ = a.enc.Encode(record)
+}
+
+// Middleware records every call of the tools it wraps.
+func (a *Auditor) Middleware(next server.ToolHandlerFunc) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		record := Record{
+			Time:      time.Now(),
+			Client:    auth.Anonymous,
+			Tool:      request.Params.Name,
+			Arguments: RedactArguments(request.GetArguments()),
+		}
+		if id, ok := auth.IdentityFromContext(ctx); ok {
+			record.Client, record.AuthMethod = id.Name, id.Method
+		}
+		if session := server.ClientSessionFromContext(ctx); session != nil {
+			record.Session = session.SessionID()
+			if s, ok := session.(server.SessionWithClientInfo); ok {
+				info := s.GetClientInfo()
+				if info.Name != "" {
+					record.ClientInfo = info.Name + "/" + info.Version
+				}
+			}
+		}
+
+		result, err := next(ctx, request)
+
+		record.DurationMs = time.Since(record.Time).Milliseconds()
+		record.ResultBytes = resultSize(result)
+		if err != nil {
+			record.IsError, record.Error = true, err.Error()
+		} else if result != nil && result.IsError {
+			record.IsError = true
+			record.Error = resultText(result)
+		}
+		a.write(record)
+		return result, err
+	}
+}
+
+// RedactArguments hides the values of sensitive arguments and the passwords in URLs.
+func RedactArguments(arguments map[string]any) map[string]any {
+	if len(arguments) == 0 {
+		return nil
+	}
+	results := make(map[string]any, len(arguments))
+	for k, v := range arguments {
+		if utils.IsSensitiveKey(k) {
+			results[k] = utils.Redacted
+			continue
+		}
+		if s, ok := v.(string); ok {
+			v = utils.RedactURL(s)
+		}
+		results[k] = v
+	}
+	return results
+}
+
+func resultSize(result *mcp.CallToolResult) int {
+	if result == nil {
+		return 0
+	}
+	size := 0
+	for _, content := range result.Content {
+		switch c := content.(type) {
+		case mcp.TextContent:
+			size += len(c.Text)
+		case mcp.ImageContent:
+			size += len(c.Data)
+		case mcp.EmbeddedResource:
+			if r, ok := c.Resource.(mcp.TextResourceContents); ok {
+				size += len(r.Text)
+			}
+		}
+	}
+	return size
+}
+
+func resultText(result *mcp.CallToolResult) string {
+	for _, content := range result.Content {
+		if c, ok := content.(mcp.TextContent); ok {
+			return c.Text
+		}
+	}
+	return ""
+}
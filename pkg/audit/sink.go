@@ -0,0 +1,90 @@
+package audit
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// NewSink opens the sink of audit records, "stdout" or "-" streams records to stdout and "stderr"
+// to stderr, otherwise records are appended to the file at path, rotated once it exceeds maxSizeMB.
+func NewSink(path string, maxSizeMB, maxBackups int) (io.Writer, error) {
+	switch path {
+	case "stdout", "-":
+		return os.Stdout, nil
+	case "stderr":
+		return os.Stderr, nil
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, fmt.Errorf("create audit log dir error: %w", err)
+	}
+	w := &RotatingFile{path: path, maxSize: int64(maxSizeMB) << 20, maxBackups: maxBackups}
+	if err := w.open(); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+// RotatingFile is a file rotated by size, keeping maxBackups old files as path.1, path.2, ...
+type RotatingFile struct {
+	lock       sync.Mutex
+	path       string
+	maxSize    int64
+	maxBackups int
+	file       *os.File
+	size       int64
+}
+
+func (w *RotatingFile) open() error {
+	f, err := os.OpenFile(w.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return fmt.Errorf("open audit log error: %w", err)
+	}
+	info, err := f.Stat()
+	if err != nil {
+		_ = f.Close()
+		return fmt.Errorf("stat audit log error: %w", err)
+	}
+	w.file, w.size = f, info.Size()
+	return nil
+}
+
+func (w *RotatingFile) rotate() error {
+	if err := w.file.Close(); err != nil {
+		return err
+	}
+	if w.maxBackups <= 0 {
+		if err := os.Remove(w.path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return w.open()
+	}
+	for i := w.maxBackups - 1; i >= 1; i-- {
+		_ = os.Rename(fmt.Sprintf("%s.%d", w.path, i), fmt.Sprintf("%s.%d", w.path, i+1))
+	}
+	if err := os.Rename(w.path, w.path+".1"); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return w.open()
+}
+
+func (w *RotatingFile) Write(p []byte) (int, error) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	if w.maxSize > 0 && w.size > 0 && w.size+int64(len(p)) > w.maxSize {
+		if err := w.rotate(); err != nil {
+			return 0, fmt.Errorf("rotate audit log error: %w", err)
+		}
+	}
+	n, err := w.file.Write(p)
+	w.size += int64(n)
+	return n, err
+}
+
+func (w *RotatingFile) Close() error {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	return w.file.Close()
+}
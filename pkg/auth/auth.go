@@ -0,0 +1,157 @@
+package auth
+
+import (
+	"bufio"
+	"context"
+	"crypto/subtle"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+
+	"go.uber.org/zap"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+
+	"juicefs-mcp/pkg/utils/logger"
+)
+
+const (
+	MethodToken = "token"
+	MethodCert  = "cert"
+)
+
+// Identity is the authenticated client of a request.
+type Identity struct {
+	Name   string
+	Method string
+}
+
+type identityKey struct{}
+
+func WithIdentity(ctx context.Context, id Identity) context.Context {
+	return context.WithValue(ctx, identityKey{}, id)
+}
+
+// IdentityFromContext returns the identity of the client calling, if authenticated.
+func IdentityFromContext(ctx context.Context) (Identity, bool) {
+	id, ok := ctx.Value(identityKey{}).(Identity)
+	return id, ok
+}
+
+// Authenticator authenticates requests of the network transports, either by a static
+// bearer token or by a verified client certificate whose CN is allowed.
+type Authenticator struct {
+	log *zap.SugaredLogger
+	// tokens maps a bearer token to the name of its client
+	tokens     map[string]string
+	allowedCNs map[string]bool
+	// clientCert is whether client certificates are verified, i.e. mTLS is enabled
+	clientCert bool
+}
+
+func NewAuthenticator() *Authenticator {
+	return &Authenticator{
+		log:        logger.NewLogger("auth"),
+		tokens:     map[string]string{},
+		allowedCNs: map[string]bool{},
+	}
+}
+
+// Enabled returns whether any authentication method is configured.
+func (a *Authenticator) Enabled() bool {
+	return len(a.tokens) > 0 || a.clientCert
+}
+
+// EnableClientCert accepts verified client certificates, limited to the CNs in allowedCNs if not empty.
+func (a *Authenticator) EnableClientCert(allowedCNs []string) {
+	a.clientCert = true
+	for _, cn := range allowedCNs {
+		if cn = strings.TrimSpace(cn); cn != "" {
+			a.allowedCNs[cn] = true
+		}
+	}
+}
+
+// LoadTokenFile loads tokens from a file with one "<name>:<token>" per line,
+// blank lines and lines starting with "#" are ignored.
+func (a *Authenticator) LoadTokenFile(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("open token file error: %w", err)
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		name, token, ok := strings.Cut(line, ":")
+		if !ok || strings.TrimSpace(token) == "" {
+			return fmt.Errorf("invalid token at %s line %d, expect <name>:<token>", path, lineNo)
+		}
+		a.tokens[strings.TrimSpace(token)] = strings.TrimSpace(name)
+	}
+	return scanner.Err()
+}
+
+// LoadTokenSecret loads tokens from a Kubernetes Secret "<namespace>/<name>",
+// each key of the Secret is the name of a client and its value is the token.
+func (a *Authenticator) LoadTokenSecret(ctx context.Context, client kubernetes.Interface, secretRef string) error {
+	namespace, name, ok := strings.Cut(secretRef, "/")
+	if !ok {
+		return fmt.Errorf("invalid secret %q, expect <namespace>/<name>", secretRef)
+	}
+	secret, err := client.CoreV1().Secrets(namespace).Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		return fmt.Errorf("get token secret error: %w", err)
+	}
+	for key, value := range secret.Data {
+		token := strings.TrimSpace(string(value))
+		if token == "" {
+			continue
+		}
+		a.tokens[token] = key
+	}
+	return nil
+}
+
+// Authenticate returns the identity of the request, or false if it is not authenticated.
+func (a *Authenticator) Authenticate(r *http.Request) (Identity, bool) {
+	if a.clientCert && r.TLS != nil && len(r.TLS.VerifiedChains) > 0 {
+		cn := r.TLS.VerifiedChains[0][0].Subject.CommonName
+		if len(a.allowedCNs) == 0 || a.allowedCNs[cn] {
+			return Identity{Name: cn, Method: MethodCert}, true
+		}
+		a.log.Debugw("client certificate not allowed", "cn", cn)
+	}
+
+	scheme, token, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return Identity{}, false
+	}
+	token = strings.TrimSpace(token)
+	for t, name := range a.tokens {
+		if subtle.ConstantTimeCompare([]byte(t), []byte(token)) == 1 {
+			return Identity{Name: name, Method: MethodToken}, true
+		}
+	}
+	return Identity{}, false
+}
+
+// Middleware rejects unauthenticated requests before they reach the MCP server,
+// and puts the identity of authenticated ones into the request context.
+func (a *Authenticator) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id, ok := a.Authenticate(r)
+		if !ok {
+			a.log.Infow("reject unauthenticated request", "remote", r.RemoteAddr, "path", r.URL.Path)
+			w.Header().Set("WWW-Authenticate", `Bearer realm="juicefs-mcp-server"`)
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(WithIdentity(r.Context(), id)))
+	})
+}
@@ -0,0 +1,148 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync/atomic"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"go.uber.org/zap"
+	"gopkg.in/yaml.v3"
+
+	"juicefs-mcp/pkg/tools"
+	"juicefs-mcp/pkg/utils/logger"
+)
+
+const (
+	// Anonymous is the identity of clients not authenticated, e.g. over stdio.
+	Anonymous = "anonymous"
+	// AnyClient matches any client in a policy rule.
+	AnyClient = "*"
+)
+
+// Policy maps client identities to the tiers and tools they may call, e.g.
+//
+//	rules:
+//	  - clients: ["*"]
+//	    tiers: [read-only]
+//	  - clients: [ops]
+//	    tiers: [read-only, sensitive-read]
+//	    tools: [juicefs_bench_in_juicefs]
+//
+// A client may call a tool if any rule matching the client allows the tier or the name of the tool.
+type Policy struct {
+	Rules []PolicyRule `yaml:"rules"`
+}
+
+type PolicyRule struct {
+	Clients []string     `yaml:"clients"`
+	Tiers   []tools.Tier `yaml:"tiers"`
+	Tools   []string     `yaml:"tools"`
+}
+
+// AllowAllPolicy allows every client to call every tool, it's used when no policy is configured.
+var AllowAllPolicy = &Policy{Rules: []PolicyRule{{
+	Clients: []string{AnyClient},
+	Tiers:   []tools.Tier{tools.TierReadOnly, tools.TierSensitiveRead, tools.TierMutating},
+}}}
+
+func LoadPolicy(path string) (*Policy, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read policy error: %w", err)
+	}
+	policy := &Policy{}
+	if err := yaml.Unmarshal(data, policy); err != nil {
+		return nil, fmt.Errorf("parse policy %s error: %w", path, err)
+	}
+	for _, rule := range policy.Rules {
+		for _, tier := range rule.Tiers {
+			switch tier {
+			case tools.TierReadOnly, tools.TierSensitiveRead, tools.TierMutating:
+			default:
+				return nil, fmt.Errorf("unknown tier %q in policy %s", tier, path)
+			}
+		}
+	}
+	return policy, nil
+}
+
+// Allowed returns whether client may call tool.
+func (p *Policy) Allowed(client string, tool tools.Tool) bool {
+	for _, rule := range p.Rules {
+		if !rule.matchClient(client) {
+			continue
+		}
+		for _, tier := range rule.Tiers {
+			if tier == tool.Tier {
+				return true
+			}
+		}
+		for _, name := range rule.Tools {
+			if name == tool.Tool.Name {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (r PolicyRule) matchClient(client string) bool {
+	for _, c := range r.Clients {
+		if c == AnyClient || c == client {
+			return true
+		}
+	}
+	return false
+}
+
+// Authorizer enforces the policy on tools/list and tools/call, the policy can be replaced at runtime.
+type Authorizer struct {
+	log    *zap.SugaredLogger
+	policy atomic.Pointer[Policy]
+}
+
+func NewAuthorizer(policy *Policy) *Authorizer {
+	a := &Authorizer{log: logger.NewLogger("authz")}
+	a.SetPolicy(policy)
+	return a
+}
+
+func (a *Authorizer) SetPolicy(policy *Policy) {
+	a.policy.Store(policy)
+}
+
+func clientName(ctx context.Context) string {
+	if id, ok := IdentityFromContext(ctx); ok {
+		return id.Name
+	}
+	return Anonymous
+}
+
+// FilterTools hides the tools the client may not call from tools/list.
+func (a *Authorizer) FilterTools(ctx context.Context, mcpTools []mcp.Tool) []mcp.Tool {
+	policy, client := a.policy.Load(), clientName(ctx)
+	results := make([]mcp.Tool, 0, len(mcpTools))
+	for _, t := range mcpTools {
+		tool, ok := tools.GetTool(t.Name)
+		if ok && policy.Allowed(client, tool) {
+			results = append(results, t)
+		}
+	}
+	return results
+}
+
+// Middleware rejects calls of tools the client may not call.
+func (a *Authorizer) Middleware(next server.ToolHandlerFunc) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		client, name := clientName(ctx), request.Params.Name
+		tool, ok := tools.GetTool(name)
+		if !ok || !a.policy.Load().Allowed(client, tool) {
+			a.log.Infow("reject unauthorized tool call", "client", client, "tool", name)
+			return nil, fmt.Errorf("client %s is not allowed to call tool %s", client, name)
+		}
+		return next(ctx, request)
+	}
+}
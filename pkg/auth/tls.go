@@ -0,0 +1,114 @@
+package auth
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"juicefs-mcp/pkg/utils/logger"
+)
+
+// certCheckInterval is how often the certificate files are checked for changes.
+const certCheckInterval = 30 * time.Second
+
+// CertReloader serves a certificate pair from disk, reloading it when the files change
+// so that rotated certificates are used without restarting the server.
+type CertReloader struct {
+	log      *zap.SugaredLogger
+	certFile string
+	keyFile  string
+
+	lock      sync.Mutex
+	cert      *tls.Certificate
+	modTime   time.Time
+	checkedAt time.Time
+}
+
+func NewCertReloader(certFile, keyFile string) (*CertReloader, error) {
+	r := &CertReloader{
+		log:      logger.NewLogger("tls"),
+		certFile: certFile,
+		keyFile:  keyFile,
+	}
+	if err := r.reload(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (r *CertReloader) latestModTime() (time.Time, error) {
+	latest := time.Time{}
+	for _, file := range []string{r.certFile, r.keyFile} {
+		info, err := os.Stat(file)
+		if err != nil {
+			return latest, err
+		}
+		if info.ModTime().After(latest) {
+			latest = info.ModTime()
+		}
+	}
+	return latest, nil
+}
+
+func (r *CertReloader) reload() error {
+	modTime, err := r.latestModTime()
+	if err != nil {
+		return fmt.Errorf("stat certificate error: %w", err)
+	}
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return fmt.Errorf("load certificate error: %w", err)
+	}
+	r.cert = &cert
+	r.modTime = modTime
+	return nil
+}
+
+// GetCertificate implements tls.Config.GetCertificate.
+func (r *CertReloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if time.Since(r.checkedAt) < certCheckInterval {
+		return r.cert, nil
+	}
+	r.checkedAt = time.Now()
+	modTime, err := r.latestModTime()
+	if err != nil || !modTime.After(r.modTime) {
+		return r.cert, nil
+	}
+	if err := r.reload(); err != nil {
+		// keep serving the old certificate until the new one is complete
+		r.log.Warnw("reload certificate error", "error", err)
+		return r.cert, nil
+	}
+	r.log.Infow("certificate reloaded", "cert", r.certFile)
+	return r.cert, nil
+}
+
+// NewTLSConfig builds the TLS config of the server. If clientCAFile is set, client
+// certificates signed by it are verified, but not required so that token clients still work.
+func NewTLSConfig(reloader *CertReloader, clientCAFile string) (*tls.Config, error) {
+	config := &tls.Config{
+		MinVersion:     tls.VersionTLS12,
+		GetCertificate: reloader.GetCertificate,
+	}
+	if clientCAFile == "" {
+		return config, nil
+	}
+	ca, err := os.ReadFile(clientCAFile)
+	if err != nil {
+		return nil, fmt.Errorf("read client ca error: %w", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(ca) {
+		return nil, fmt.Errorf("no certificate found in client ca %s", clientCAFile)
+	}
+	config.ClientCAs = pool
+	config.ClientAuth = tls.VerifyClientCertIfGiven
+	return config, nil
+}
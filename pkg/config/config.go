@@ -0,0 +1,150 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	EditionCE = "ce"
+	EditionEE = "ee"
+)
+
+// Config is the configuration of the server, loaded from a YAML file and overridden by flags.
+// LogLevel, Limits and Auth.PolicyFile are reloaded when the file changes, other fields
+// take effect on restart.
+type Config struct {
+	// Transport is stdio, sse or http
+	Transport string `yaml:"transport"`
+	// Listen is the listen address of sse and http transport
+	Listen string `yaml:"listen"`
+	// BaseURL is the public base url of sse and http transport, default http(s)://<listen>
+	BaseURL     string   `yaml:"baseURL"`
+	HTTPPath    string   `yaml:"httpPath"`
+	MetricsAddr string   `yaml:"metricsAddr"`
+	Handlers    []string `yaml:"handlers"`
+	LogLevel    string   `yaml:"logLevel"`
+	// DataDir keeps the state of the server like the bench history, empty disables it
+	DataDir string `yaml:"dataDir"`
+
+	CSI      CSIConfig      `yaml:"csi"`
+	JuiceFS  JuiceFSConfig  `yaml:"juicefs"`
+	Timeouts TimeoutsConfig `yaml:"timeouts"`
+	Limits   LimitsConfig   `yaml:"limits"`
+	Auth     AuthConfig     `yaml:"auth"`
+	Audit    AuditConfig    `yaml:"audit"`
+}
+
+type CSIConfig struct {
+	// SysNamespace is the namespace of JuiceFS CSI driver
+	SysNamespace string `yaml:"sysNamespace"`
+}
+
+type JuiceFSConfig struct {
+	BinPath string `yaml:"binPath"`
+	// Edition is ce (Community Edition) or ee (Enterprise Edition), used when the binary can't be probed
+	Edition string `yaml:"edition"`
+	// RulesDir has YAML rule files of the tuning advisor, merged over the embedded rules by rule id
+	RulesDir string `yaml:"rulesDir"`
+}
+
+type TimeoutsConfig struct {
+	// Command bounds the run time of juicefs subprocesses
+	Command time.Duration `yaml:"command"`
+	// Shutdown bounds the graceful shutdown of sse and http transport
+	Shutdown time.Duration `yaml:"shutdown"`
+}
+
+type LimitsConfig struct {
+	// MaxOutputBytes truncates the text returned by a tool call into a JSON envelope marked
+	// truncated, 0 means unlimited
+	MaxOutputBytes int `yaml:"maxOutputBytes"`
+	// ToolTimeout bounds the run time of a tool call, 0 means unlimited. Tools taking their own
+	// timeout, e.g. bench and warmup, may ask for a longer one
+	ToolTimeout time.Duration `yaml:"toolTimeout"`
+}
+
+type AuthConfig struct {
+	TokenFile     string   `yaml:"tokenFile"`
+	TokenSecret   string   `yaml:"tokenSecret"`
+	TLSCert       string   `yaml:"tlsCert"`
+	TLSKey        string   `yaml:"tlsKey"`
+	TLSClientCA   string   `yaml:"tlsClientCA"`
+	TLSAllowedCNs []string `yaml:"tlsAllowedCNs"`
+	PolicyFile    string   `yaml:"policyFile"`
+}
+
+type AuditConfig struct {
+	// Path is a file path, stdout or stderr, empty disables audit log
+	Path       string `yaml:"path"`
+	MaxSizeMB  int    `yaml:"maxSizeMB"`
+	MaxBackups int    `yaml:"maxBackups"`
+}
+
+func Default() *Config {
+	return &Config{
+		Transport:   "sse",
+		Listen:      "0.0.0.0:8088",
+		HTTPPath:    "/mcp",
+		MetricsAddr: "127.0.0.1:8089",
+		Handlers:    []string{"csi"},
+		LogLevel:    "info",
+		DataDir:     "/var/lib/juicefs-mcp",
+		CSI:         CSIConfig{SysNamespace: "kube-system"},
+		JuiceFS:     JuiceFSConfig{BinPath: "/usr/bin/juicefs", Edition: EditionCE},
+		Timeouts:    TimeoutsConfig{Command: 5 * time.Minute, Shutdown: 5 * time.Second},
+		Limits:      LimitsConfig{MaxOutputBytes: 1 << 20, ToolTimeout: 10 * time.Minute},
+		Audit:       AuditConfig{MaxSizeMB: 100, MaxBackups: 5},
+	}
+}
+
+// Load loads the config file at path over the defaults, an empty path returns the defaults.
+func Load(path string) (*Config, error) {
+	cfg := Default()
+	if path == "" {
+		return cfg, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read config error: %w", err)
+	}
+	if err := yaml.Unmarshal(data, cfg); err != nil {
+		return nil, fmt.Errorf("parse config %s error: %w", path, err)
+	}
+	return cfg, nil
+}
+
+func (c *Config) Validate() error {
+	switch c.Transport {
+	case "stdio", "sse", "http":
+	default:
+		return fmt.Errorf("invalid transport %q, must be stdio, sse or http", c.Transport)
+	}
+	switch c.JuiceFS.Edition {
+	case EditionCE, EditionEE:
+	default:
+		return fmt.Errorf("invalid juicefs edition %q, must be ce or ee", c.JuiceFS.Edition)
+	}
+	if c.Timeouts.Command <= 0 || c.Timeouts.Shutdown <= 0 {
+		return fmt.Errorf("timeouts must be positive")
+	}
+	if c.Limits.MaxOutputBytes < 0 || c.Limits.ToolTimeout < 0 {
+		return fmt.Errorf("limits must not be negative")
+	}
+	return nil
+}
+
+// RestartRequired returns whether new differs from c in fields that can't be reloaded.
+func (c *Config) RestartRequired(new *Config) bool {
+	oldCopy, newCopy := *c, *new
+	for _, cfg := range []*Config{&oldCopy, &newCopy} {
+		cfg.LogLevel = ""
+		cfg.Limits = LimitsConfig{}
+		cfg.Auth.PolicyFile = ""
+	}
+	return !reflect.DeepEqual(oldCopy, newCopy)
+}
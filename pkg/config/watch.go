@@ -0,0 +1,54 @@
+package config
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"time"
+)
+
+// Watch calls onChange whenever the content of any file returned by paths changes, until ctx is done.
+// Files are polled instead of watched by inotify, so that ConfigMaps updated by symlink swaps work too.
+func Watch(ctx context.Context, interval time.Duration, paths func() []string, onChange func()) {
+	snapshot := func() map[string][]byte {
+		contents := map[string][]byte{}
+		for _, path := range paths() {
+			if path == "" {
+				continue
+			}
+			// a missing file is treated as empty, it's reported when it's loaded
+			data, _ := os.ReadFile(path)
+			contents[path] = data
+		}
+		return contents
+	}
+
+	last := snapshot()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			current := snapshot()
+			if changed(last, current) {
+				onChange()
+			}
+			last = current
+		}
+	}
+}
+
+func changed(last, current map[string][]byte) bool {
+	if len(last) != len(current) {
+		return true
+	}
+	for path, data := range current {
+		old, ok := last[path]
+		if !ok || !bytes.Equal(old, data) {
+			return true
+		}
+	}
+	return false
+}
@@ -31,27 +31,24 @@ type PodStatus struct {
 }
 
 func (c *CSIHandler) handleGetHandleFlow(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
-	c.log.Debugw("handleGetHandleFlow", "request", request.Params.Arguments)
+	c.log.Debugw("handleGetHandleFlow", "request", request.GetArguments())
 	return mcp.NewToolResultText(`
 排查业务容器挂载问题时，可以通过以下步骤进行：
-1. 判断 PVC 是否和 PV 绑定成功，使用 tool get_juicefs_pv_of_app_pod;
-2. 判断 Mount Pod 是否创建成功并正常运行，使用 tool get_mount_pod_by_pv;
-3. 如果 Mount Pod 已经创建，查看 Mount Pod 的日志，使用 tool get_log_of_pod;
-4. 如果 Mount Pod 没有创建，查看 CSI Node Pod 的日志，使用 tool get_csi_node_pod + get_log_of_pod;
+1. 判断 PVC 是否和 PV 绑定成功，使用 tool csi_get_juicefs_pv_of_app_pod;
+2. 判断 Mount Pod 是否创建成功并正常运行，使用 tool csi_get_mount_pod_by_pv;
+3. 如果 Mount Pod 已经创建，查看 Mount Pod 的日志，使用 tool csi_get_log_of_pod;
+4. 如果 Mount Pod 没有创建，查看 CSI Node Pod 的日志，使用 tool csi_get_csi_node_pod + csi_get_log_of_pod;
 `), nil
 }
 
-func (c *CSIHandler) handleGetPod(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
-	c.log.Debugw("handleGetName", "argument", request.Params.Arguments)
-	podName, ok := request.Params.Arguments["podName"].(string)
-	if !ok {
-		c.log.Errorw("Missing argument")
-		return nil, fmt.Errorf("missing podName")
-	}
-	namespace, ok := request.Params.Arguments["namespace"].(string)
-	if !ok {
-		namespace = "default"
-	}
+type GetPodArgs struct {
+	PodName   string `json:"podName" desc:"pod 名" mcp:"required"`
+	Namespace string `json:"namespace" desc:"pod 的 namespace" mcp:"default=default"`
+}
+
+func (c *CSIHandler) handleGetPod(ctx context.Context, request mcp.CallToolRequest, args GetPodArgs) (*mcp.CallToolResult, error) {
+	c.log.Debugw("handleGetName", "argument", args)
+	podName, namespace := args.PodName, args.Namespace
 
 	pod, err := c.client.CoreV1().Pods(namespace).Get(ctx, podName, metav1.GetOptions{})
 	if err != nil {
@@ -80,20 +77,15 @@ func (c *CSIHandler) handleGetPod(ctx context.Context, request mcp.CallToolReque
 	return mcp.NewToolResultText(fmt.Sprintf("%+v", string(res))), nil
 }
 
-func (c *CSIHandler) handlePodLog(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
-	c.log.Debugw("handlePodLog", "argument", request.Params.Arguments)
-	podName, ok := request.Params.Arguments["podName"].(string)
-	if !ok {
-		return nil, fmt.Errorf("missing podName")
-	}
-	namespace, ok := request.Params.Arguments["namespace"].(string)
-	if !ok {
-		return nil, fmt.Errorf("missing namespace")
-	}
-	tail, ok := request.Params.Arguments["tailLines"].(int64)
-	if !ok {
-		tail = int64(20)
-	}
+type PodLogArgs struct {
+	PodName   string `json:"podName" desc:"Pod 名称" mcp:"required"`
+	Namespace string `json:"namespace" desc:"Pod 的 namespace" mcp:"required"`
+	TailLines int64  `json:"tailLines" desc:"获取的日志行数" mcp:"default=20,min=1,max=10000"`
+}
+
+func (c *CSIHandler) handlePodLog(ctx context.Context, request mcp.CallToolRequest, args PodLogArgs) (*mcp.CallToolResult, error) {
+	c.log.Debugw("handlePodLog", "argument", args)
+	podName, namespace, tail := args.PodName, args.Namespace, args.TailLines
 
 	pod, err := c.client.CoreV1().Pods(namespace).Get(ctx, podName, metav1.GetOptions{})
 	if err != nil && !k8serrors.IsNotFound(err) {
@@ -120,6 +112,6 @@ func (c *CSIHandler) handlePodLog(ctx context.Context, request mcp.CallToolReque
 	}
 	str := buf.String()
 
-	c.log.Debugw("Pod Log", "podName", podName, "namespace", namespace, "tailLines", tail, "logs", str)
+	c.log.Debugw("Pod Log", "podName", podName, "namespace", namespace, "tailLines", tail, "bytes", len(str))
 	return mcp.NewToolResultText(str), nil
 }
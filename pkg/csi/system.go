@@ -25,13 +25,13 @@ type NodeStatus struct {
 	Conditions  []corev1.NodeCondition
 }
 
-func (c *CSIHandler) handleGetCSINodePod(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
-	c.log.Debugw("handleGetCSINodePod", "argument", request.Params.Arguments)
-	nodeName, ok := request.Params.Arguments["nodeName"].(string)
-	if !ok {
-		c.log.Errorw("Missing argument", "nodeName", nodeName)
-		return nil, fmt.Errorf("missing nodeName")
-	}
+type NodeArgs struct {
+	NodeName string `json:"nodeName" desc:"节点名" mcp:"required"`
+}
+
+func (c *CSIHandler) handleGetCSINodePod(ctx context.Context, request mcp.CallToolRequest, args NodeArgs) (*mcp.CallToolResult, error) {
+	c.log.Debugw("handleGetCSINodePod", "argument", args)
+	nodeName := args.NodeName
 
 	csiNode, err := c.GetCSINode(ctx, nodeName)
 	if err != nil {
@@ -59,13 +59,9 @@ func (c *CSIHandler) handleGetCSINodePod(ctx context.Context, request mcp.CallTo
 	return mcp.NewToolResultText(fmt.Sprintf("%+v", string(res))), nil
 }
 
-func (c *CSIHandler) handleGetNode(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
-	c.log.Debugw("handleGetNode", "argument", request.Params.Arguments)
-	nodeName, ok := request.Params.Arguments["nodeName"].(string)
-	if !ok {
-		c.log.Errorw("Missing argument", "nodeName", nodeName)
-		return nil, fmt.Errorf("missing nodeName")
-	}
+func (c *CSIHandler) handleGetNode(ctx context.Context, request mcp.CallToolRequest, args NodeArgs) (*mcp.CallToolResult, error) {
+	c.log.Debugw("handleGetNode", "argument", args)
+	nodeName := args.NodeName
 
 	node, err := c.client.CoreV1().Nodes().Get(ctx, nodeName, metav1.GetOptions{})
 	if err != nil {
@@ -90,18 +86,14 @@ func (c *CSIHandler) handleGetNode(ctx context.Context, request mcp.CallToolRequ
 	return mcp.NewToolResultText(fmt.Sprintf("%+v", string(res))), nil
 }
 
-func (c *CSIHandler) handleGetMountPodByPV(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
-	c.log.Debugw("handleGetMountPodByPV", "argument", request.Params.Arguments)
-	pvName, ok := request.Params.Arguments["pvName"].(string)
-	if !ok {
-		c.log.Errorw("Missing argument", "pvName", pvName)
-		return nil, fmt.Errorf("missing pvName")
-	}
-	nodeName, ok := request.Params.Arguments["nodeName"].(string)
-	if !ok {
-		c.log.Errorw("Missing argument", "nodeName", nodeName)
-		return nil, fmt.Errorf("missing nodeName")
-	}
+type MountPodArgs struct {
+	NodeName string `json:"nodeName" desc:"节点名" mcp:"required"`
+	PVName   string `json:"pvName" desc:"PV 名称" mcp:"required"`
+}
+
+func (c *CSIHandler) handleGetMountPodByPV(ctx context.Context, request mcp.CallToolRequest, args MountPodArgs) (*mcp.CallToolResult, error) {
+	c.log.Debugw("handleGetMountPodByPV", "argument", args)
+	pvName, nodeName := args.PVName, args.NodeName
 
 	pv, err := c.client.CoreV1().PersistentVolumes().Get(ctx, pvName, metav1.GetOptions{})
 	if err != nil && !k8serrors.IsNotFound(err) {
@@ -151,22 +143,15 @@ func (c *CSIHandler) handleGetMountPodByPV(ctx context.Context, request mcp.Call
 	return mcp.NewToolResultText(fmt.Sprintf("%+v", string(res))), nil
 }
 
-func (c *CSIHandler) handleMountPodLogByPV(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
-	c.log.Debugw("handleMountPodLogByPV", "argument", request.Params.Arguments)
-	pvName, ok := request.Params.Arguments["pvName"].(string)
-	if !ok {
-		c.log.Errorw("Missing argument", "pvName", pvName)
-		return nil, fmt.Errorf("missing pvName")
-	}
-	nodeName, ok := request.Params.Arguments["nodeName"].(string)
-	if !ok {
-		c.log.Errorw("Missing argument", "nodeName", nodeName)
-		return nil, fmt.Errorf("missing nodeName")
-	}
-	tail, ok := request.Params.Arguments["tailLines"].(int64)
-	if !ok {
-		tail = int64(20)
-	}
+type MountPodLogArgs struct {
+	NodeName  string `json:"nodeName" desc:"节点名" mcp:"required"`
+	PVName    string `json:"pvName" desc:"PV 名称" mcp:"required"`
+	TailLines int64  `json:"tailLines" desc:"获取的日志行数" mcp:"default=20,min=1,max=10000"`
+}
+
+func (c *CSIHandler) handleMountPodLogByPV(ctx context.Context, request mcp.CallToolRequest, args MountPodLogArgs) (*mcp.CallToolResult, error) {
+	c.log.Debugw("handleMountPodLogByPV", "argument", args)
+	pvName, nodeName, tail := args.PVName, args.NodeName, args.TailLines
 
 	pv, err := c.client.CoreV1().PersistentVolumes().Get(ctx, pvName, metav1.GetOptions{})
 	if err != nil && !k8serrors.IsNotFound(err) {
@@ -217,6 +202,6 @@ func (c *CSIHandler) handleMountPodLogByPV(ctx context.Context, request mcp.Call
 	}
 	str := buf.String()
 
-	c.log.Debugw("Pod Log", "mount pod name", mountPod.Name, "namespace", mountPod.Namespace, "tailLines", tail, "logs", str)
+	c.log.Debugw("Pod Log", "mount pod name", mountPod.Name, "namespace", mountPod.Namespace, "tailLines", tail, "bytes", len(str))
 	return mcp.NewToolResultText(str), nil
 }
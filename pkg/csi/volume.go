@@ -18,17 +18,14 @@ type PVWithStatus struct {
 	Status    corev1.PersistentVolumeStatus
 }
 
-func (c *CSIHandler) handleGetJuiceFSPVOfApp(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
-	c.log.Debugw("handleGetJuiceFSPVCOfApp", "argument", request.Params.Arguments)
-	namespace, ok := request.Params.Arguments["namespace"].(string)
-	if !ok {
-		namespace = "default"
-	}
-	appName, ok := request.Params.Arguments["appName"].(string)
-	if !ok {
-		c.log.Errorw("Missing argument", "appName", appName)
-		return nil, fmt.Errorf("missing appName")
-	}
+type AppPVArgs struct {
+	AppName   string `json:"appName" desc:"应用 Pod 名称" mcp:"required"`
+	Namespace string `json:"namespace" desc:"应用 Pod 的 namespace" mcp:"default=default"`
+}
+
+func (c *CSIHandler) handleGetJuiceFSPVOfApp(ctx context.Context, request mcp.CallToolRequest, args AppPVArgs) (*mcp.CallToolResult, error) {
+	c.log.Debugw("handleGetJuiceFSPVCOfApp", "argument", args)
+	namespace, appName := args.Namespace, args.AppName
 
 	var (
 		pod   *corev1.Pod
@@ -0,0 +1,330 @@
+package juicefs
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// AccessLogFile is the hidden file of a mountpoint that streams the operations of the client
+// while it's open.
+const AccessLogFile = ".accesslog"
+
+const accessLogTimeLayout = "2006.01.02 15:04:05.000000"
+
+// accessLogLine matches a line of the access log, e.g.
+//
+//	2021.01.15 08:26:11.003330 [uid:0,gid:0,pid:4403] write (17669,8666,4993160,19): OK <0.000010>
+//	2021.01.15 08:26:11.003825 [uid:0,gid:0,pid:4403] lookup (1,"test-file"): OK (17665,[-rw-r--r--:0100644,1,0,0]) <0.000011>
+var accessLogLine = regexp.MustCompile(`^(\d{4}\.\d{2}\.\d{2} \d{2}:\d{2}:\d{2}\.\d+) \[uid:(\d+),gid:(\d+),pid:(\d+)\] (\w+) \((.*?)\): (.*?) ?<([\d.]+)>$`)
+
+// AccessLogEntry is one operation in the access log.
+type AccessLogEntry struct {
+	Time time.Time
+	UID  int
+	GID  int
+	PID  int
+	Op   string
+	// Args are the raw arguments, the first one is the inode for most ops
+	Args  []string
+	Inode uint64
+	// Name is the quoted entry name argument of ops like lookup, create and mkdir
+	Name string
+	// Result is OK or the error
+	Result   string
+	Detail   string
+	Duration time.Duration
+	Raw      string
+}
+
+// ParseAccessLogLine parses a line of the access log.
+func ParseAccessLogLine(line string) (AccessLogEntry, error) {
+	m := accessLogLine.FindStringSubmatch(line)
+	if m == nil {
+		return AccessLogEntry{}, fmt.Errorf("unknown access log format")
+	}
+	e := AccessLogEntry{Op: m[5], Raw: line}
+	var err error
+	if e.Time, err = time.ParseInLocation(accessLogTimeLayout, m[1], time.Local); err != nil {
+		return e, err
+	}
+	e.UID, _ = strconv.Atoi(m[2])
+	e.GID, _ = strconv.Atoi(m[3])
+	e.PID, _ = strconv.Atoi(m[4])
+	for _, arg := range splitOutsideQuotes(m[6]) {
+		arg = strings.TrimSpace(arg)
+		e.Args = append(e.Args, arg)
+		if e.Name == "" && strings.HasPrefix(arg, `"`) {
+			e.Name = strings.Trim(arg, `"`)
+		}
+	}
+	if len(e.Args) > 0 {
+		e.Inode, _ = strconv.ParseUint(e.Args[0], 10, 64)
+	}
+	e.Result = m[7]
+	if strings.HasPrefix(m[7], "OK") {
+		e.Result, e.Detail = "OK", strings.TrimSpace(strings.TrimPrefix(m[7], "OK"))
+	}
+	seconds, err := strconv.ParseFloat(m[8], 64)
+	if err != nil {
+		return e, err
+	}
+	e.Duration = time.Duration(seconds * float64(time.Second))
+	return e, nil
+}
+
+// IntArg returns the i-th argument as an integer, or -1 if it's missing or not an integer.
+func (e AccessLogEntry) IntArg(i int) int64 {
+	if i >= len(e.Args) {
+		return -1
+	}
+	v, err := strconv.ParseInt(e.Args[i], 10, 64)
+	if err != nil {
+		return -1
+	}
+	return v
+}
+
+// ReadAccessLog streams the access log of mountpoint for duration, calling handle on every
+// parsed entry. It returns the number of lines that couldn't be parsed.
+func ReadAccessLog(ctx context.Context, mountpoint string, duration time.Duration, handle func(AccessLogEntry)) (int, error) {
+	f, err := os.Open(filepath.Join(mountpoint, AccessLogFile))
+	if err != nil {
+		return 0, fmt.Errorf("open access log error: %w", err)
+	}
+	// closing the file stops the reader, which may be blocked in read until the next op
+	defer f.Close()
+
+	lines, done := make(chan string, 1024), make(chan struct{})
+	defer close(done)
+	go func() {
+		defer close(lines)
+		scanner := bufio.NewScanner(f)
+		scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+		for scanner.Scan() {
+			select {
+			case lines <- scanner.Text():
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	timer := time.NewTimer(duration)
+	defer timer.Stop()
+	unparsed := 0
+	for {
+		select {
+		case <-ctx.Done():
+			return unparsed, ctx.Err()
+		case <-timer.C:
+			return unparsed, nil
+		case line, ok := <-lines:
+			if !ok {
+				return unparsed, nil
+			}
+			// the client writes "#" to keep the reader alive when there is no op
+			if line = strings.TrimSpace(line); line == "" || line == "#" {
+				continue
+			}
+			entry, err := ParseAccessLogLine(line)
+			if err != nil {
+				unparsed++
+				continue
+			}
+			handle(entry)
+		}
+	}
+}
+
+// splitOutsideQuotes splits s by commas outside of quoted strings.
+func splitOutsideQuotes(s string) []string {
+	parts := []string{}
+	quoted, start := false, 0
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case '"':
+			quoted = !quoted
+		case ',':
+			if !quoted {
+				parts = append(parts, s[start:i])
+				start = i + 1
+			}
+		}
+	}
+	if strings.TrimSpace(s[start:]) != "" {
+		parts = append(parts, s[start:])
+	}
+	return parts
+}
+
+// AccessLogFilter selects entries by op, inode and pid, zero values match all.
+type AccessLogFilter struct {
+	Ops   []string
+	Inode uint64
+	PID   int
+}
+
+func (f AccessLogFilter) Match(e AccessLogEntry) bool {
+	return (len(f.Ops) == 0 || slices.Contains(f.Ops, e.Op)) &&
+		(f.Inode == 0 || f.Inode == e.Inode) &&
+		(f.PID == 0 || f.PID == e.PID)
+}
+
+// OpSummary is the count and latency of one op in the access log.
+type OpSummary struct {
+	Op     string  `json:"op"`
+	Count  int     `json:"count"`
+	Errors int     `json:"errors"`
+	P50Ms  float64 `json:"p50Ms"`
+	P95Ms  float64 `json:"p95Ms"`
+	P99Ms  float64 `json:"p99Ms"`
+	MaxMs  float64 `json:"maxMs"`
+	// TotalMs is the time spent in the op, the ops taking the most time come first
+	TotalMs float64 `json:"totalMs"`
+}
+
+// TopItem is a key and the number of ops on it.
+type TopItem struct {
+	Key   string `json:"key"`
+	Count int    `json:"count"`
+}
+
+type AccessLogReport struct {
+	Mountpoint  string      `json:"mountpoint"`
+	DurationSec int         `json:"durationSec"`
+	Entries     int         `json:"entries"`
+	Matched     int         `json:"matched"`
+	Unparsed    int         `json:"unparsed"`
+	Ops         []OpSummary `json:"ops"`
+	TopInodes   []TopItem   `json:"topInodes"`
+	// TopPaths are the entry names looked up or created, as <parent inode>/<name>,
+	// the access log doesn't record full paths
+	TopPaths []TopItem `json:"topPaths"`
+	TopPIDs  []TopItem `json:"topPids"`
+	// Lines are the raw lines matched, only if requested
+	Lines          []string `json:"lines,omitempty"`
+	LinesTruncated bool     `json:"linesTruncated,omitempty"`
+}
+
+// accessLogAnalyzer aggregates the entries matching filter.
+type accessLogAnalyzer struct {
+	filter    AccessLogFilter
+	maxLines  int
+	entries   int
+	matched   int
+	durations map[string][]float64
+	errors    map[string]int
+	inodes    map[string]int
+	paths     map[string]int
+	pids      map[string]int
+	lines     []string
+	truncated bool
+}
+
+func newAccessLogAnalyzer(filter AccessLogFilter, maxLines int) *accessLogAnalyzer {
+	return &accessLogAnalyzer{
+		filter:    filter,
+		maxLines:  maxLines,
+		durations: map[string][]float64{},
+		errors:    map[string]int{},
+		inodes:    map[string]int{},
+		paths:     map[string]int{},
+		pids:      map[string]int{},
+	}
+}
+
+func (a *accessLogAnalyzer) add(e AccessLogEntry) {
+	a.entries++
+	if !a.filter.Match(e) {
+		return
+	}
+	a.matched++
+	a.durations[e.Op] = append(a.durations[e.Op], float64(e.Duration)/float64(time.Millisecond))
+	if e.Result != "OK" {
+		a.errors[e.Op]++
+	}
+	if e.Inode != 0 {
+		a.inodes[strconv.FormatUint(e.Inode, 10)]++
+	}
+	if e.Name != "" {
+		a.paths[fmt.Sprintf("%d/%s", e.Inode, e.Name)]++
+	}
+	a.pids[strconv.Itoa(e.PID)]++
+	if a.maxLines > 0 {
+		if len(a.lines) < a.maxLines {
+			a.lines = append(a.lines, e.Raw)
+		} else {
+			a.truncated = true
+		}
+	}
+}
+
+func (a *accessLogAnalyzer) report(topN int) AccessLogReport {
+	r := AccessLogReport{
+		Entries:        a.entries,
+		Matched:        a.matched,
+		Ops:            []OpSummary{},
+		TopInodes:      topItems(a.inodes, topN),
+		TopPaths:       topItems(a.paths, topN),
+		TopPIDs:        topItems(a.pids, topN),
+		Lines:          a.lines,
+		LinesTruncated: a.truncated,
+	}
+	for op, durations := range a.durations {
+		sort.Float64s(durations)
+		s := OpSummary{
+			Op:     op,
+			Count:  len(durations),
+			Errors: a.errors[op],
+			P50Ms:  percentile(durations, 0.5),
+			P95Ms:  percentile(durations, 0.95),
+			P99Ms:  percentile(durations, 0.99),
+			MaxMs:  durations[len(durations)-1],
+		}
+		for _, d := range durations {
+			s.TotalMs += d
+		}
+		r.Ops = append(r.Ops, s)
+	}
+	sort.Slice(r.Ops, func(i, j int) bool { return r.Ops[i].TotalMs > r.Ops[j].TotalMs })
+	return r
+}
+
+// percentile returns the q percentile of sorted values by the nearest rank.
+func percentile(sorted []float64, q float64) float64 {
+	if len(sorted) == 0 {
+		return 0
+	}
+	rank := int(math.Ceil(q*float64(len(sorted)))) - 1
+	return sorted[max(rank, 0)]
+}
+
+// topItems returns the n keys with the largest counts.
+func topItems(counts map[string]int, n int) []TopItem {
+	items := make([]TopItem, 0, len(counts))
+	for key, count := range counts {
+		items = append(items, TopItem{Key: key, Count: count})
+	}
+	sort.Slice(items, func(i, j int) bool {
+		if items[i].Count != items[j].Count {
+			return items[i].Count > items[j].Count
+		}
+		return items[i].Key < items[j].Key
+	})
+	if len(items) > n {
+		items = items[:n]
+	}
+	return items
+}
@@ -0,0 +1,147 @@
+package juicefs
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestParseAccessLogLine(t *testing.T) {
+	tests := []struct {
+		line   string
+		op     string
+		inode  uint64
+		args   int
+		name   string
+		result string
+		detail string
+		pid    int
+		dur    time.Duration
+	}{
+		{
+			line: "2021.01.15 08:26:11.003330 [uid:0,gid:0,pid:4403] write (17669,8666,4993160,19): OK <0.000010>",
+			op:   "write", inode: 17669, args: 4, result: "OK", pid: 4403, dur: 10 * time.Microsecond,
+		},
+		{
+			line: `2021.01.15 08:26:11.003825 [uid:0,gid:0,pid:4403] lookup (1,"test-file"): OK (17665,[-rw-r--r--:0100644,1,0,0]) <0.000011>`,
+			op:   "lookup", inode: 1, args: 2, name: "test-file", result: "OK", detail: "(17665,[-rw-r--r--:0100644,1,0,0])", pid: 4403, dur: 11 * time.Microsecond,
+		},
+		{
+			line: `2021.01.15 08:26:11.100000 [uid:1000,gid:1000,pid:5501] lookup (1,"a,b"): no such file or directory <0.000050>`,
+			op:   "lookup", inode: 1, args: 2, name: "a,b", result: "no such file or directory", pid: 5501, dur: 50 * time.Microsecond,
+		},
+		{
+			line: "2021.01.15 08:26:11.200000 [uid:0,gid:0,pid:1] statfs (): OK 1024 <0.000100>",
+			op:   "statfs", args: 0, result: "OK", detail: "1024", pid: 1, dur: 100 * time.Microsecond,
+		},
+	}
+	for _, tt := range tests {
+		e, err := ParseAccessLogLine(tt.line)
+		if err != nil {
+			t.Errorf("ParseAccessLogLine(%q) error: %v", tt.line, err)
+			continue
+		}
+		if e.Op != tt.op || e.Inode != tt.inode || len(e.Args) != tt.args || e.Name != tt.name ||
+			e.Result != tt.result || e.Detail != tt.detail || e.PID != tt.pid || e.Duration != tt.dur {
+			t.Errorf("ParseAccessLogLine(%q) = %+v", tt.line, e)
+		}
+	}
+
+	e, _ := ParseAccessLogLine(tests[0].line)
+	if e.IntArg(1) != 8666 || e.IntArg(2) != 4993160 || e.IntArg(3) != 19 || e.IntArg(4) != -1 {
+		t.Errorf("IntArg of %v", e.Args)
+	}
+	for _, line := range []string{"", "#", "not an access log line", "2021.01.15 08:26:11.003330 [uid:0,gid:0,pid:4403] write (17669): OK"} {
+		if _, err := ParseAccessLogLine(line); err == nil {
+			t.Errorf("ParseAccessLogLine(%q) succeeded", line)
+		}
+	}
+}
+
+// accessLogMount returns a dir with the access log fixture as its .accesslog.
+func accessLogMount(t *testing.T) string {
+	data, err := os.ReadFile("testdata/accesslog")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, AccessLogFile), data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
+
+func TestAccessLogReport(t *testing.T) {
+	analyzer := newAccessLogAnalyzer(AccessLogFilter{}, 2)
+	unparsed, err := ReadAccessLog(context.Background(), accessLogMount(t), time.Minute, analyzer.add)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if unparsed != 1 {
+		t.Errorf("unparsed = %d, want 1", unparsed)
+	}
+	r := analyzer.report(2)
+	if r.Entries != 10 || r.Matched != 10 {
+		t.Errorf("entries %d matched %d, want 10", r.Entries, r.Matched)
+	}
+	ops := map[string]OpSummary{}
+	for _, op := range r.Ops {
+		ops[op.Op] = op
+	}
+	if read := ops["read"]; read.Count != 4 || read.P50Ms != 2 || read.P95Ms != 4 || read.MaxMs != 4 || read.TotalMs != 10 {
+		t.Errorf("read summary %+v", read)
+	}
+	if lookup := ops["lookup"]; lookup.Count != 2 || lookup.Errors != 1 {
+		t.Errorf("lookup summary %+v", lookup)
+	}
+	// the ops taking the most time come first
+	if r.Ops[0].Op != "flush" {
+		t.Errorf("first op is %s, want flush", r.Ops[0].Op)
+	}
+	if len(r.TopInodes) != 2 || r.TopInodes[0] != (TopItem{"17665", 5}) || r.TopInodes[1] != (TopItem{"1", 3}) {
+		t.Errorf("top inodes %+v", r.TopInodes)
+	}
+	if len(r.TopPIDs) != 2 || r.TopPIDs[0] != (TopItem{"4403", 6}) {
+		t.Errorf("top pids %+v", r.TopPIDs)
+	}
+	if len(r.TopPaths) != 2 || r.TopPaths[0].Key != `1/a, \"quoted\" name` {
+		t.Errorf("top paths %+v", r.TopPaths)
+	}
+	if len(r.Lines) != 2 || !r.LinesTruncated {
+		t.Errorf("got %d lines, truncated %v, want 2 truncated", len(r.Lines), r.LinesTruncated)
+	}
+}
+
+func TestAccessLogFilter(t *testing.T) {
+	for _, tt := range []struct {
+		filter  AccessLogFilter
+		matched int
+	}{
+		{AccessLogFilter{PID: 5501}, 4},
+		{AccessLogFilter{Inode: 17665}, 5},
+		{AccessLogFilter{Ops: []string{"read", "write"}}, 5},
+		{AccessLogFilter{Ops: []string{"read"}, PID: 5501}, 0},
+	} {
+		analyzer := newAccessLogAnalyzer(tt.filter, 0)
+		if _, err := ReadAccessLog(context.Background(), accessLogMount(t), time.Minute, analyzer.add); err != nil {
+			t.Fatal(err)
+		}
+		if r := analyzer.report(10); r.Matched != tt.matched || r.Entries != 10 || r.Lines != nil {
+			t.Errorf("filter %+v matched %d of %d, want %d", tt.filter, r.Matched, r.Entries, tt.matched)
+		}
+	}
+}
+
+func TestPercentile(t *testing.T) {
+	sorted := []float64{1, 2, 3, 4, 5, 6, 7, 8, 9, 10}
+	for q, want := range map[float64]float64{0: 1, 0.5: 5, 0.95: 10, 0.99: 10, 1: 10} {
+		if got := percentile(sorted, q); got != want {
+			t.Errorf("percentile(%g) = %g, want %g", q, got, want)
+		}
+	}
+	if got := percentile(nil, 0.5); got != 0 {
+		t.Errorf("percentile of nothing = %g", got)
+	}
+}
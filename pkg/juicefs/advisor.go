@@ -0,0 +1,435 @@
+package juicefs
+
+import (
+	"context"
+	"embed"
+	"fmt"
+	"io/fs"
+	"math"
+	"path"
+	"reflect"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"gopkg.in/yaml.v3"
+	"k8s.io/apimachinery/pkg/util/json"
+)
+
+// ruleFiles are the rules of the tuning advisor, a list of rules per yaml file.
+//
+//go:embed rules/*.yaml
+var ruleFiles embed.FS
+
+// severities of the findings, the most severe first
+const (
+	SeverityCritical = "critical"
+	SeverityWarning  = "warning"
+	SeverityInfo     = "info"
+)
+
+var severities = []string{SeverityCritical, SeverityWarning, SeverityInfo}
+
+// Rule is a declarative check of the tuning advisor.
+type Rule struct {
+	ID       string `yaml:"id"`
+	Severity string `yaml:"severity"`
+	// Doc is the topic of the tuning doc behind the rule
+	Doc   string `yaml:"doc"`
+	Title string `yaml:"title"`
+	// Message explains the finding, ${fact} is replaced by the value of the fact
+	Message string `yaml:"message"`
+	// the rule fires if all of All hold and one of Any holds, if set
+	All       []Condition      `yaml:"all"`
+	Any       []Condition      `yaml:"any"`
+	Recommend []Recommendation `yaml:"recommend"`
+}
+
+// Condition compares a fact with Value, or with the fact Ref times Factor.
+type Condition struct {
+	Fact   string  `yaml:"fact"`
+	Op     string  `yaml:"op"`
+	Value  any     `yaml:"value"`
+	Ref    string  `yaml:"ref"`
+	Factor float64 `yaml:"factor"`
+}
+
+// Recommendation is a change of a mount flag, to Value or to the fact Ref times Factor.
+type Recommendation struct {
+	Flag   string  `yaml:"flag"`
+	Value  string  `yaml:"value"`
+	Ref    string  `yaml:"ref"`
+	Factor float64 `yaml:"factor"`
+	// Remove is set when the option should be dropped, e.g. -o writeback_cache
+	Remove bool `yaml:"remove"`
+}
+
+// Facts are the values the rules are evaluated against, either float64 or bool.
+type Facts map[string]any
+
+// Finding is a rule that fires.
+type Finding struct {
+	Rule      string       `json:"rule"`
+	Severity  string       `json:"severity"`
+	Title     string       `json:"title"`
+	Message   string       `json:"message"`
+	Evidence  []Evidence   `json:"evidence"`
+	Recommend []FlagChange `json:"recommend"`
+	Doc       string       `json:"doc,omitempty"`
+}
+
+// Evidence is a condition that holds, Expected is the value the fact is compared with.
+type Evidence struct {
+	Fact     string `json:"fact"`
+	Value    any    `json:"value"`
+	Op       string `json:"op"`
+	Expected any    `json:"expected"`
+}
+
+// FlagChange is a recommended mount flag, e.g. --buffer-size=1024.
+type FlagChange struct {
+	Flag   string `json:"flag"`
+	Value  string `json:"value,omitempty"`
+	Remove bool   `json:"remove,omitempty"`
+}
+
+// SkippedRule is a rule that can't be evaluated since some facts are unknown.
+type SkippedRule struct {
+	Rule    string   `json:"rule"`
+	Missing []string `json:"missing"`
+}
+
+// LoadRules loads the rules of all yaml files in dir.
+func LoadRules(fsys fs.FS, dir string) ([]Rule, error) {
+	files, err := fs.Glob(fsys, path.Join(dir, "*.yaml"))
+	if err != nil {
+		return nil, err
+	}
+	rules := []Rule{}
+	ids := map[string]string{}
+	for _, file := range files {
+		data, err := fs.ReadFile(fsys, file)
+		if err != nil {
+			return nil, err
+		}
+		var fileRules []Rule
+		if err := yaml.Unmarshal(data, &fileRules); err != nil {
+			return nil, fmt.Errorf("parse rules %s error: %w", file, err)
+		}
+		for _, rule := range fileRules {
+			if err := rule.validate(); err != nil {
+				return nil, fmt.Errorf("invalid rule %q in %s: %w", rule.ID, file, err)
+			}
+			if prev, ok := ids[rule.ID]; ok {
+				return nil, fmt.Errorf("duplicate rule %q in %s and %s", rule.ID, prev, file)
+			}
+			ids[rule.ID] = file
+			rules = append(rules, rule)
+		}
+	}
+	return rules, nil
+}
+
+// MergeRules returns the rules of base with own merged over them, a rule of own replaces the rule of
+// base with its id and the others are added.
+func MergeRules(base, own []Rule) []Rule {
+	rules := slices.Clone(base)
+	index := map[string]int{}
+	for i, rule := range rules {
+		index[rule.ID] = i
+	}
+	for _, rule := range own {
+		if i, ok := index[rule.ID]; ok {
+			rules[i] = rule
+			continue
+		}
+		index[rule.ID] = len(rules)
+		rules = append(rules, rule)
+	}
+	return rules
+}
+
+func (r Rule) validate() error {
+	if r.ID == "" {
+		return fmt.Errorf("missing id")
+	}
+	if !slices.Contains(severities, r.Severity) {
+		return fmt.Errorf("invalid severity %q, must be one of %s", r.Severity, strings.Join(severities, ", "))
+	}
+	if len(r.All)+len(r.Any) == 0 {
+		return fmt.Errorf("no conditions")
+	}
+	for _, c := range append(slices.Clone(r.All), r.Any...) {
+		if c.Fact == "" {
+			return fmt.Errorf("condition without fact")
+		}
+		if (c.Value == nil) == (c.Ref == "") {
+			return fmt.Errorf("condition on %s must have either value or ref", c.Fact)
+		}
+		switch c.Op {
+		case "==", "!=":
+		case "<", "<=", ">", ">=":
+			if _, ok := c.Value.(bool); ok {
+				return fmt.Errorf("condition on %s compares bool with %s", c.Fact, c.Op)
+			}
+		default:
+			return fmt.Errorf("invalid op %q on %s", c.Op, c.Fact)
+		}
+	}
+	for _, rec := range r.Recommend {
+		if rec.Flag == "" {
+			return fmt.Errorf("recommendation without flag")
+		}
+	}
+	return nil
+}
+
+// Advisor evaluates the rules against the facts of a mount.
+type Advisor struct {
+	rules []Rule
+}
+
+func NewAdvisor(rules []Rule) *Advisor {
+	return &Advisor{rules: rules}
+}
+
+// Evaluate returns the findings sorted by severity, and the rules skipped for missing facts.
+func (a *Advisor) Evaluate(facts Facts) ([]Finding, []SkippedRule) {
+	findings, skipped := []Finding{}, []SkippedRule{}
+	for _, rule := range a.rules {
+		if missing := rule.missingFacts(facts); len(missing) > 0 {
+			skipped = append(skipped, SkippedRule{Rule: rule.ID, Missing: missing})
+			continue
+		}
+		evidence, ok := rule.evaluate(facts)
+		if !ok {
+			continue
+		}
+		finding := Finding{
+			Rule:      rule.ID,
+			Severity:  rule.Severity,
+			Title:     rule.Title,
+			Message:   expandFacts(strings.TrimSpace(rule.Message), facts),
+			Evidence:  evidence,
+			Recommend: []FlagChange{},
+		}
+		if rule.Doc != "" {
+			finding.Doc = docURI(rule.Doc)
+		}
+		for _, rec := range rule.Recommend {
+			change := FlagChange{Flag: rec.Flag, Value: rec.Value, Remove: rec.Remove}
+			if rec.Ref != "" {
+				v, _ := facts[rec.Ref].(float64)
+				change.Value = formatFact(math.Round(v * factor(rec.Factor)))
+			}
+			finding.Recommend = append(finding.Recommend, change)
+		}
+		findings = append(findings, finding)
+	}
+	sort.SliceStable(findings, func(i, j int) bool {
+		return slices.Index(severities, findings[i].Severity) < slices.Index(severities, findings[j].Severity)
+	})
+	return findings, skipped
+}
+
+// missingFacts returns the facts used by the conditions of the rule but not known.
+func (r Rule) missingFacts(facts Facts) []string {
+	missing := []string{}
+	for _, c := range append(slices.Clone(r.All), r.Any...) {
+		for _, name := range []string{c.Fact, c.Ref} {
+			if _, ok := facts[name]; name != "" && !ok && !slices.Contains(missing, name) {
+				missing = append(missing, name)
+			}
+		}
+	}
+	return missing
+}
+
+// evaluate returns the conditions that hold as evidence, and whether the rule fires.
+func (r Rule) evaluate(facts Facts) ([]Evidence, bool) {
+	evidence := []Evidence{}
+	for _, c := range r.All {
+		e, ok := c.evaluate(facts)
+		if !ok {
+			return nil, false
+		}
+		evidence = append(evidence, e)
+	}
+	matched := len(r.Any) == 0
+	for _, c := range r.Any {
+		if e, ok := c.evaluate(facts); ok {
+			evidence = append(evidence, e)
+			matched = true
+		}
+	}
+	return evidence, matched
+}
+
+func (c Condition) evaluate(facts Facts) (Evidence, bool) {
+	e := Evidence{Fact: c.Fact, Value: facts[c.Fact], Op: c.Op, Expected: c.Value}
+	if c.Ref != "" {
+		ref, _ := facts[c.Ref].(float64)
+		e.Expected = ref * factor(c.Factor)
+	}
+	if b, ok := e.Value.(bool); ok {
+		expected, isBool := e.Expected.(bool)
+		if !isBool {
+			return e, false
+		}
+		return e, (c.Op == "==") == (b == expected)
+	}
+	v, ok := toFloat(e.Value)
+	expected, isNumber := toFloat(e.Expected)
+	if !ok || !isNumber {
+		return e, false
+	}
+	switch c.Op {
+	case "==":
+		return e, v == expected
+	case "!=":
+		return e, v != expected
+	case "<":
+		return e, v < expected
+	case "<=":
+		return e, v <= expected
+	case ">":
+		return e, v > expected
+	case ">=":
+		return e, v >= expected
+	}
+	return e, false
+}
+
+// factor defaults to 1 when not set.
+func factor(f float64) float64 {
+	if f == 0 {
+		return 1
+	}
+	return f
+}
+
+// toFloat converts the numbers decoded from yaml.
+func toFloat(v any) (float64, bool) {
+	switch n := v.(type) {
+	case float64:
+		return n, true
+	case int:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	}
+	return 0, false
+}
+
+var factRef = regexp.MustCompile(`\$\{([\w.]+)\}`)
+
+// expandFacts replaces ${fact} in s by the value of the fact.
+func expandFacts(s string, facts Facts) string {
+	return factRef.ReplaceAllStringFunc(s, func(m string) string {
+		v, ok := facts[m[2:len(m)-1]]
+		if !ok {
+			return "unknown"
+		}
+		if f, ok := v.(float64); ok {
+			return formatFact(f)
+		}
+		return fmt.Sprint(v)
+	})
+}
+
+func formatFact(v float64) string {
+	return strconv.FormatFloat(math.Round(v*100)/100, 'f', -1, 64)
+}
+
+// mountFacts are the numeric and bool options of a mount, named mount.<json name>.
+func mountFacts(facts Facts, opts *MountOptions) {
+	v := reflect.ValueOf(opts).Elem()
+	for i := 0; i < v.NumField(); i++ {
+		name, _, _ := strings.Cut(v.Type().Field(i).Tag.Get("json"), ",")
+		if name == "pid" {
+			continue
+		}
+		switch field := v.Field(i); field.Kind() {
+		case reflect.Bool:
+			facts["mount."+name] = field.Bool()
+		case reflect.Int, reflect.Int64:
+			facts["mount."+name] = float64(field.Int())
+		case reflect.Float64:
+			facts["mount."+name] = field.Float()
+		}
+	}
+	facts["mount.writebackCache"] = slices.Contains(opts.FuseOptions, "writeback_cache")
+}
+
+// statsFacts are the average and maximum of the columns of the samples, named stats.<column>.avg|max.
+func statsFacts(facts Facts, summary []ColumnSummary) {
+	for _, s := range summary {
+		facts["stats."+s.Column+".avg"] = s.Avg
+		facts["stats."+s.Column+".max"] = s.Max
+	}
+}
+
+type AdviseArgs struct {
+	Mountpoint string `json:"mountpoint" desc:"挂载点" mcp:"required"`
+	Samples    int    `json:"samples" desc:"采样 .stats 的次数，0 表示不采样，依赖监控指标的规则会被跳过" mcp:"default=5,min=0,max=60"`
+	Interval   int    `json:"interval" desc:"采样间隔，单位秒" mcp:"default=1,min=1,max=60"`
+}
+
+type AdviceReport struct {
+	Mountpoint string        `json:"mountpoint"`
+	ClientPID  int           `json:"clientPid"`
+	Findings   []Finding     `json:"findings"`
+	Skipped    []SkippedRule `json:"skipped"`
+	CacheDisks []CacheDisk   `json:"cacheDisks"`
+	Facts      Facts         `json:"facts"`
+}
+
+func (j *JuiceFSHandler) handleAdvise(
+	ctx context.Context,
+	request mcp.CallToolRequest,
+	args AdviseArgs,
+) (*mcp.CallToolResult, error) {
+	mountpoint := args.Mountpoint
+	j.log.Debugw("handleAdvise", "args", args)
+
+	opts, err := j.mountOptions(ctx, mountpoint)
+	if err != nil {
+		return nil, fmt.Errorf("advise error: %w", err)
+	}
+	facts := Facts{}
+	mountFacts(facts, opts)
+	if args.Samples > 0 {
+		if err := j.checkStatsMount(ctx, mountpoint); err != nil {
+			return nil, fmt.Errorf("advise error: %w", err)
+		}
+		snapshots, err := CollectSnapshots(ctx, mountpoint, args.Samples, time.Second*time.Duration(args.Interval), j.mounts.StatfsTimeout)
+		if err != nil {
+			return nil, fmt.Errorf("advise error: %w", err)
+		}
+		statsFacts(facts, collectStats(mountpoint, snapshots).Summary)
+	}
+	disks := j.cacheDisks(ctx, opts)
+	diskFacts(facts, disks)
+
+	findings, skipped := j.advisor.Evaluate(facts)
+	for _, f := range findings {
+		for i := range f.Recommend {
+			f.Recommend[i].Flag = j.flagName(f.Recommend[i].Flag)
+		}
+	}
+	report := AdviceReport{
+		Mountpoint: mountpoint,
+		ClientPID:  opts.PID,
+		Findings:   findings,
+		Skipped:    skipped,
+		CacheDisks: disks,
+		Facts:      facts,
+	}
+	j.log.Debugw("advise", "mountpoint", mountpoint, "findings", len(findings), "skipped", len(skipped))
+	res, _ := json.Marshal(report)
+	return mcp.NewToolResultText(fmt.Sprintf("%+v", string(res))), nil
+}
@@ -0,0 +1,73 @@
+package juicefs
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+func TestLoadEmbeddedRules(t *testing.T) {
+	rules, err := LoadRules(ruleFiles, "rules")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rules) == 0 {
+		t.Fatal("no embedded rules")
+	}
+}
+
+func TestLoadRulesOfRulesDir(t *testing.T) {
+	dir := t.TempDir()
+	own := `
+- id: buffer-congested
+  severity: info
+  title: replaced
+  message: replaced
+  all:
+    - fact: stats.usage.buf.max
+      op: ">"
+      value: 0
+- id: my-rule
+  severity: warning
+  title: added
+  message: added
+  all:
+    - fact: mount.bufferSize
+      op: "<"
+      value: 100
+`
+	if err := os.WriteFile(filepath.Join(dir, "my.yaml"), []byte(own), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	embedded, err := LoadRules(ruleFiles, "rules")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	j := &JuiceFSHandler{log: zap.NewNop().Sugar(), rulesDir: dir}
+	rules, err := j.loadRules()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rules) != len(embedded)+1 {
+		t.Fatalf("got %d rules, want %d embedded and 1 added", len(rules), len(embedded))
+	}
+	titles := map[string]string{}
+	for _, rule := range rules {
+		titles[rule.ID] = rule.Title
+	}
+	if titles["buffer-congested"] != "replaced" || titles["my-rule"] != "added" {
+		t.Errorf("rules not merged: %v", titles)
+	}
+
+	// an invalid rules dir leaves the embedded rules alone
+	if err := os.WriteFile(filepath.Join(dir, "bad.yaml"), []byte("- id: bad\n  severity: fatal\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	rules, err = j.loadRules()
+	if err != nil || len(rules) != len(embedded) {
+		t.Errorf("got %d rules and error %v, want the %d embedded", len(rules), err, len(embedded))
+	}
+}
@@ -0,0 +1,453 @@
+package juicefs
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"k8s.io/apimachinery/pkg/util/json"
+)
+
+// verdicts of the detectors
+const (
+	VerdictLikely       = "likely"
+	VerdictUnlikely     = "unlikely"
+	VerdictInsufficient = "insufficient-data"
+)
+
+const (
+	// minReads is the number of reads needed to judge the read pattern
+	minReads = 20
+	// sequentialGap is the largest gap between two reads of a handle still taken as sequential,
+	// the kernel may reorder or skip a little within its readahead window
+	sequentialGap = 128 << 10
+	// smallReadSize is the size below which a read is small
+	smallReadSize = 128 << 10
+	// randomRatio is the ratio of random reads above which the read pattern is random
+	randomRatio = 0.5
+	// amplificationRatio is the ratio of object download to FUSE read above which reads are amplified
+	amplificationRatio = 2.0
+)
+
+type DetectArgs struct {
+	Mountpoint string `json:"mountpoint" desc:"挂载点" mcp:"required"`
+	Interval   int    `json:"interval" desc:"采集访问日志和监控指标的时长，单位秒" mcp:"default=10,min=1,max=120"`
+}
+
+// ReadPattern is the pattern of the reads in the access log, grouped by file handle.
+type ReadPattern struct {
+	Reads           int     `json:"reads"`
+	Handles         int     `json:"handles"`
+	SequentialReads int     `json:"sequentialReads"`
+	RandomReads     int     `json:"randomReads"`
+	SequentialRatio float64 `json:"sequentialRatio"`
+	AvgReadBytes    float64 `json:"avgReadBytes"`
+	// AvgJumpBytes is the average distance between the end of a read and the offset of
+	// the next random read of the same handle
+	AvgJumpBytes float64 `json:"avgJumpBytes"`
+	MaxJumpBytes int64   `json:"maxJumpBytes"`
+}
+
+// handleReads tracks the last read of a file handle.
+type handleReads struct {
+	end int64
+}
+
+// readPatternAnalyzer groups the reads of the access log by file handle.
+type readPatternAnalyzer struct {
+	handles   map[string]*handleReads
+	pattern   ReadPattern
+	bytes     int64
+	jumpBytes int64
+}
+
+func newReadPatternAnalyzer() *readPatternAnalyzer {
+	return &readPatternAnalyzer{handles: map[string]*handleReads{}}
+}
+
+// add takes a read, whose arguments are (inode, size, offset, fh).
+func (a *readPatternAnalyzer) add(e AccessLogEntry) {
+	if e.Op != "read" || e.Result != "OK" {
+		return
+	}
+	size, offset, fh := e.IntArg(1), e.IntArg(2), e.IntArg(3)
+	if size < 0 || offset < 0 {
+		return
+	}
+	a.pattern.Reads++
+	a.bytes += size
+	key := fmt.Sprintf("%d/%d", e.Inode, fh)
+	h, ok := a.handles[key]
+	if !ok {
+		a.handles[key] = &handleReads{end: offset + size}
+		return
+	}
+	jump := offset - h.end
+	if jump < 0 {
+		jump = -jump
+	}
+	if jump <= sequentialGap {
+		a.pattern.SequentialReads++
+	} else {
+		a.pattern.RandomReads++
+		a.jumpBytes += jump
+		a.pattern.MaxJumpBytes = max(a.pattern.MaxJumpBytes, jump)
+	}
+	h.end = offset + size
+}
+
+func (a *readPatternAnalyzer) result() ReadPattern {
+	p := a.pattern
+	p.Handles = len(a.handles)
+	if p.Reads > 0 {
+		p.AvgReadBytes = float64(a.bytes) / float64(p.Reads)
+	}
+	// the first read of a handle is neither sequential nor random
+	if followed := p.SequentialReads + p.RandomReads; followed > 0 {
+		p.SequentialRatio = float64(p.SequentialReads) / float64(followed)
+	}
+	if p.RandomReads > 0 {
+		p.AvgJumpBytes = float64(a.jumpBytes) / float64(p.RandomReads)
+	}
+	return p
+}
+
+type ReadAmplificationReport struct {
+	Mountpoint  string      `json:"mountpoint"`
+	DurationSec int         `json:"durationSec"`
+	Pattern     ReadPattern `json:"pattern"`
+	// throughput of the same window from .stats
+	FuseReadBytesPerSec      float64 `json:"fuseReadBytesPerSec"`
+	ObjectGetBytesPerSec     float64 `json:"objectGetBytesPerSec"`
+	BlockCacheHitBytesPerSec float64 `json:"blockCacheHitBytesPerSec"`
+	// Amplification is object download divided by FUSE read
+	Amplification  float64  `json:"amplification"`
+	Verdict        string   `json:"verdict"`
+	Evidence       []string `json:"evidence"`
+	Recommendation string   `json:"recommendation,omitempty"`
+	Doc            string   `json:"doc"`
+}
+
+// judgeReadAmplification judges whether the reads are amplified by prefetch: random small
+// reads jumping far apart, while much more is downloaded from the object storage than is read.
+func judgeReadAmplification(r *ReadAmplificationReport) {
+	p := r.Pattern
+	if r.FuseReadBytesPerSec > 0 {
+		r.Amplification = r.ObjectGetBytesPerSec / r.FuseReadBytesPerSec
+	}
+	if p.Reads < minReads {
+		r.Verdict = VerdictInsufficient
+		r.Evidence = append(r.Evidence, fmt.Sprintf("only %d reads in %ds, at least %d are needed", p.Reads, r.DurationSec, minReads))
+		return
+	}
+
+	random := 1-p.SequentialRatio >= randomRatio
+	small := p.AvgReadBytes < smallReadSize
+	amplified := r.Amplification >= amplificationRatio || (r.FuseReadBytesPerSec == 0 && r.ObjectGetBytesPerSec > 0)
+	r.Evidence = append(r.Evidence,
+		fmt.Sprintf("%.0f%% of %d reads over %d handles are random (offset jumps over %s)", (1-p.SequentialRatio)*100, p.Reads, p.Handles, humanize(sequentialGap, "B")),
+		fmt.Sprintf("average read size is %s, small reads are under %s", humanize(p.AvgReadBytes, "B"), humanize(smallReadSize, "B")),
+		fmt.Sprintf("average offset jump of random reads is %s, max %s", humanize(p.AvgJumpBytes, "B"), humanize(float64(p.MaxJumpBytes), "B")),
+		fmt.Sprintf("object storage download %s/s vs FUSE read %s/s (%.1fx), block cache hit %s/s",
+			humanize(r.ObjectGetBytesPerSec, "B"), humanize(r.FuseReadBytesPerSec, "B"), r.Amplification, humanize(r.BlockCacheHitBytesPerSec, "B")),
+	)
+	if random && small && amplified {
+		r.Verdict = VerdictLikely
+		r.Recommendation = "remount with --prefetch=0 to disable prefetch, which downloads whole blocks that random small reads hardly use"
+		return
+	}
+	r.Verdict = VerdictUnlikely
+}
+
+func (j *JuiceFSHandler) handleDetectReadAmplification(
+	ctx context.Context,
+	request mcp.CallToolRequest,
+	args DetectArgs,
+) (*mcp.CallToolResult, error) {
+	mountpoint, interval := args.Mountpoint, args.Interval
+	j.log.Debugw("handleDetectReadAmplification", "args", args)
+
+	if err := j.checkStatsMount(ctx, mountpoint); err != nil {
+		return nil, fmt.Errorf("detect read amplification error: %w", err)
+	}
+	from, err := ReadSnapshot(ctx, mountpoint, j.mounts.StatfsTimeout)
+	if err != nil {
+		return nil, fmt.Errorf("detect read amplification error: %w", err)
+	}
+	analyzer := newReadPatternAnalyzer()
+	if _, err := ReadAccessLog(ctx, mountpoint, time.Second*time.Duration(interval), analyzer.add); err != nil {
+		return nil, fmt.Errorf("detect read amplification error: %w", err)
+	}
+	to, err := ReadSnapshot(ctx, mountpoint, j.mounts.StatfsTimeout)
+	if err != nil {
+		return nil, fmt.Errorf("detect read amplification error: %w", err)
+	}
+	sample := statsSample(from, to)
+
+	report := ReadAmplificationReport{
+		Mountpoint:               mountpoint,
+		DurationSec:              interval,
+		Pattern:                  analyzer.result(),
+		FuseReadBytesPerSec:      sample.Fuse.ReadBytesPerSec,
+		ObjectGetBytesPerSec:     sample.Object.GetBytesPerSec,
+		BlockCacheHitBytesPerSec: sample.BlockCache.HitBytesPerSec,
+		Evidence:                 []string{},
+		Doc:                      docURI("read-amplification"),
+	}
+	judgeReadAmplification(&report)
+	j.log.Debugw("detect read amplification", "mountpoint", mountpoint, "verdict", report.Verdict)
+	res, _ := json.Marshal(report)
+	return mcp.NewToolResultText(fmt.Sprintf("%+v", string(res))), nil
+}
+
+const (
+	// defaultBlockSize is the default block size of a volume, taken if the volume's can't be read
+	defaultBlockSize = 4 << 20
+	// defaultFlushWait is the default interval in which the client persists the written data
+	defaultFlushWait = 5 * time.Second
+	// maxFlushWait is the largest --flush-wait suggested
+	maxFlushWait = 60 * time.Second
+	// minWrites is the number of writes of a file needed to judge its write pattern
+	minWrites = 10
+	// sequentialWriteRatio is the ratio of sequential writes above which a file is written sequentially
+	sequentialWriteRatio = 0.9
+	// maxAffectedFiles bounds the number of files reported
+	maxAffectedFiles = 20
+)
+
+// FileWrites is the write pattern of a file in the access log.
+type FileWrites struct {
+	Inode uint64 `json:"inode"`
+	// Name is the entry name of the file, if it's looked up or created within the window
+	Name             string  `json:"name,omitempty"`
+	Writes           int     `json:"writes"`
+	Bytes            int64   `json:"bytes"`
+	SequentialRatio  float64 `json:"sequentialRatio"`
+	WriteBytesPerSec float64 `json:"writeBytesPerSec"`
+	Flushes          int     `json:"flushes"`
+	Fsyncs           int     `json:"fsyncs"`
+	// EstimatedSlices is the number of slices written in the window, one per flush or fsync
+	// and one per flush wait
+	EstimatedSlices int `json:"estimatedSlices"`
+
+	sequential int
+	end        map[int64]int64
+}
+
+// writePatternAnalyzer groups the writes, flushes and fsyncs of the access log by file.
+type writePatternAnalyzer struct {
+	files map[uint64]*FileWrites
+	names map[uint64]string
+}
+
+func newWritePatternAnalyzer() *writePatternAnalyzer {
+	return &writePatternAnalyzer{files: map[uint64]*FileWrites{}, names: map[uint64]string{}}
+}
+
+func (a *writePatternAnalyzer) file(inode uint64) *FileWrites {
+	f, ok := a.files[inode]
+	if !ok {
+		f = &FileWrites{Inode: inode, end: map[int64]int64{}}
+		a.files[inode] = f
+	}
+	return f
+}
+
+// add takes writes (inode, size, offset, fh), flushes (inode, fh) and fsyncs (inode, datasync, fh),
+// and the inodes returned by lookup and create to name the files.
+func (a *writePatternAnalyzer) add(e AccessLogEntry) {
+	if e.Result != "OK" {
+		return
+	}
+	switch e.Op {
+	case "write":
+		size, offset, fh := e.IntArg(1), e.IntArg(2), e.IntArg(3)
+		if size < 0 || offset < 0 {
+			return
+		}
+		f := a.file(e.Inode)
+		if end, ok := f.end[fh]; ok && end == offset {
+			f.sequential++
+		}
+		f.end[fh] = offset + size
+		f.Writes++
+		f.Bytes += size
+	case "flush":
+		a.file(e.Inode).Flushes++
+	case "fsync":
+		a.file(e.Inode).Fsyncs++
+	case "lookup", "create", "mknod":
+		// the entry is returned like (17665,[-rw-r--r--:0100644,...])
+		detail := strings.TrimPrefix(e.Detail, "(")
+		inode, err := strconv.ParseUint(strings.SplitN(detail, ",", 2)[0], 10, 64)
+		if err == nil && e.Name != "" {
+			a.names[inode] = e.Name
+		}
+	}
+}
+
+// slowSequentialWriters returns the files written sequentially so slowly that a flush wait
+// persists less than a block, sorted by the slices estimated.
+func (a *writePatternAnalyzer) slowSequentialWriters(window, flushWait time.Duration, blockSize int64) []FileWrites {
+	files := []FileWrites{}
+	for _, f := range a.files {
+		if f.Writes < minWrites {
+			continue
+		}
+		// the first write of each handle is neither sequential nor random
+		if followed := f.Writes - len(f.end); followed > 0 {
+			f.SequentialRatio = float64(f.sequential) / float64(followed)
+		}
+		f.WriteBytesPerSec = float64(f.Bytes) / window.Seconds()
+		if f.SequentialRatio < sequentialWriteRatio || f.WriteBytesPerSec*flushWait.Seconds() >= float64(blockSize) {
+			continue
+		}
+		f.EstimatedSlices = f.Flushes + f.Fsyncs + int(window/flushWait)
+		f.Name = a.names[f.Inode]
+		files = append(files, *f)
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].EstimatedSlices > files[j].EstimatedSlices })
+	if len(files) > maxAffectedFiles {
+		files = files[:maxAffectedFiles]
+	}
+	return files
+}
+
+type WriteFragmentationReport struct {
+	Mountpoint    string       `json:"mountpoint"`
+	DurationSec   int          `json:"durationSec"`
+	AffectedFiles []FileWrites `json:"affectedFiles"`
+	// throughput of the same window from .stats
+	FuseWriteBytesPerSec float64 `json:"fuseWriteBytesPerSec"`
+	ObjectPutBytesPerSec float64 `json:"objectPutBytesPerSec"`
+	ObjectPutOpsPerSec   float64 `json:"objectPutOpsPerSec"`
+	CompactionsPerSec    float64 `json:"compactionsPerSec"`
+	CompactBytesPerSec   float64 `json:"compactBytesPerSec"`
+	// CurrentFlushWait is --flush-wait in seconds of the client, the default if it's not set
+	CurrentFlushWait float64 `json:"currentFlushWait"`
+	// BlockSizeBytes is the block size of the volume, a slice shorter than it is a fragment
+	BlockSizeBytes int64    `json:"blockSizeBytes"`
+	ClientPID      int      `json:"clientPid"`
+	Verdict        string   `json:"verdict"`
+	Evidence       []string `json:"evidence"`
+	Recommendation string   `json:"recommendation,omitempty"`
+	Doc            string   `json:"doc"`
+}
+
+// judgeWriteFragmentation judges whether slow sequential writers fragment files into many slices,
+// which are then compacted at the cost of object storage traffic.
+func judgeWriteFragmentation(r *WriteFragmentationReport, flushWait time.Duration) {
+	r.Evidence = append(r.Evidence,
+		fmt.Sprintf("object storage upload %s/s in %.1f puts/s, FUSE write %s/s",
+			humanize(r.ObjectPutBytesPerSec, "B"), r.ObjectPutOpsPerSec, humanize(r.FuseWriteBytesPerSec, "B")),
+		fmt.Sprintf("%.2f compactions/s rewriting %s/s", r.CompactionsPerSec, humanize(r.CompactBytesPerSec, "B")),
+	)
+	if len(r.AffectedFiles) == 0 {
+		r.Verdict = VerdictUnlikely
+		r.Evidence = append(r.Evidence, fmt.Sprintf("no file is written sequentially slower than %s per flush wait of %s", humanize(float64(r.BlockSizeBytes), "B"), flushWait))
+		return
+	}
+	slowestRate := math.Inf(1)
+	for _, f := range r.AffectedFiles {
+		r.Evidence = append(r.Evidence, fmt.Sprintf("inode %d %s is written sequentially at %s/s, about %d slices in %ds (%d flushes, %d fsyncs)",
+			f.Inode, f.Name, humanize(f.WriteBytesPerSec, "B"), f.EstimatedSlices, r.DurationSec, f.Flushes, f.Fsyncs))
+		slowestRate = math.Min(slowestRate, f.WriteBytesPerSec)
+	}
+	// small puts or compaction traffic confirm the fragments reach the object storage
+	smallPuts := r.ObjectPutOpsPerSec > 0 && r.ObjectPutBytesPerSec/r.ObjectPutOpsPerSec < float64(r.BlockSizeBytes)/2
+	if r.CompactionsPerSec == 0 && !smallPuts {
+		r.Verdict = VerdictUnlikely
+		r.Evidence = append(r.Evidence, "no compaction or small put is seen in the window")
+		return
+	}
+	r.Verdict = VerdictLikely
+	if flushWait >= maxFlushWait {
+		r.Recommendation = fmt.Sprintf("--flush-wait is already %s, consider buffering writes in the application", flushWait)
+		return
+	}
+	// wait long enough for the slowest writer to fill a block
+	suggested := time.Duration(math.Ceil(float64(r.BlockSizeBytes)/math.Max(slowestRate, 1))) * time.Second
+	suggested = min(max(suggested, flushWait*2), maxFlushWait)
+	r.Recommendation = fmt.Sprintf("remount the client with --flush-wait=%d (currently %g) to persist less often and write fewer slices; flushes and fsyncs by the application still persist immediately",
+		int(suggested.Seconds()), r.CurrentFlushWait)
+}
+
+// volumeBlockSize returns the block size of the volume mounted at mountpoint given by `juicefs status`,
+// or defaultBlockSize if it can't be read.
+func (j *JuiceFSHandler) volumeBlockSize(ctx context.Context, mountpoint string) int64 {
+	if !j.supports("status") {
+		return defaultBlockSize
+	}
+	status, _, err := j.volumeStatus(ctx, mountpoint)
+	if err != nil || status.Setting.BlockSizeKiB <= 0 {
+		j.log.Warnw("get block size of volume error, using the default", "mountpoint", mountpoint, "error", err)
+		return defaultBlockSize
+	}
+	return int64(status.Setting.BlockSizeKiB) << 10
+}
+
+func (j *JuiceFSHandler) handleDetectWriteFragmentation(
+	ctx context.Context,
+	request mcp.CallToolRequest,
+	args DetectArgs,
+) (*mcp.CallToolResult, error) {
+	mountpoint, interval := args.Mountpoint, args.Interval
+	j.log.Debugw("handleDetectWriteFragmentation", "args", args)
+
+	if err := j.checkStatsMount(ctx, mountpoint); err != nil {
+		return nil, fmt.Errorf("detect write fragmentation error: %w", err)
+	}
+	opts, err := j.mountOptions(ctx, mountpoint)
+	if err != nil {
+		return nil, fmt.Errorf("detect write fragmentation error: %w", err)
+	}
+	flushWait := time.Duration(opts.FlushWait * float64(time.Second))
+	if flushWait <= 0 {
+		flushWait = defaultFlushWait
+	}
+
+	blockSize := j.volumeBlockSize(ctx, mountpoint)
+
+	from, err := ReadSnapshot(ctx, mountpoint, j.mounts.StatfsTimeout)
+	if err != nil {
+		return nil, fmt.Errorf("detect write fragmentation error: %w", err)
+	}
+	analyzer := newWritePatternAnalyzer()
+	window := time.Second * time.Duration(interval)
+	if _, err := ReadAccessLog(ctx, mountpoint, window, analyzer.add); err != nil {
+		return nil, fmt.Errorf("detect write fragmentation error: %w", err)
+	}
+	to, err := ReadSnapshot(ctx, mountpoint, j.mounts.StatfsTimeout)
+	if err != nil {
+		return nil, fmt.Errorf("detect write fragmentation error: %w", err)
+	}
+	sample := statsSample(from, to)
+	compaction := histogram("compact", metricCompactSize, "", from, to)
+
+	report := WriteFragmentationReport{
+		Mountpoint:           mountpoint,
+		DurationSec:          interval,
+		AffectedFiles:        analyzer.slowSequentialWriters(window, flushWait, blockSize),
+		FuseWriteBytesPerSec: sample.Fuse.WriteBytesPerSec,
+		ObjectPutBytesPerSec: sample.Object.PutBytesPerSec,
+		ObjectPutOpsPerSec:   sample.Object.PutOpsPerSec,
+		CompactionsPerSec:    compaction.RatePerSec,
+		CurrentFlushWait:     opts.FlushWait,
+		BlockSizeBytes:       blockSize,
+		ClientPID:            opts.PID,
+		Evidence:             []string{},
+		Doc:                  docURI("write-amplification"),
+	}
+	if dt := to.Time.Sub(from.Time).Seconds(); dt > 0 {
+		report.CompactBytesPerSec = (to.Value(metricCompactSize, "_sum", "") - from.Value(metricCompactSize, "_sum", "")) / dt
+	}
+	judgeWriteFragmentation(&report, flushWait)
+	j.log.Debugw("detect write fragmentation", "mountpoint", mountpoint, "verdict", report.Verdict)
+	res, _ := json.Marshal(report)
+	return mcp.NewToolResultText(fmt.Sprintf("%+v", string(res))), nil
+}
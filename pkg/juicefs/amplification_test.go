@@ -0,0 +1,112 @@
+package juicefs
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+// slowWriter returns an analyzer of a file written sequentially with n writes of size bytes.
+func slowWriter(t *testing.T, n int, size int64) *writePatternAnalyzer {
+	a := newWritePatternAnalyzer()
+	for i := 0; i < n; i++ {
+		line := fmt.Sprintf("2021.01.15 08:26:11.003330 [uid:0,gid:0,pid:4403] write (17669,%d,%d,19): OK <0.000010>", size, int64(i)*size)
+		e, err := ParseAccessLogLine(line)
+		if err != nil {
+			t.Fatal(err)
+		}
+		a.add(e)
+	}
+	return a
+}
+
+func TestSlowSequentialWritersBlockSize(t *testing.T) {
+	// 128 KiB/s, 640 KiB per flush wait of 5s
+	window, flushWait := 10*time.Second, 5*time.Second
+	for _, c := range []struct {
+		blockSize int64
+		affected  int
+	}{
+		{4 << 20, 1},
+		{1 << 20, 1},
+		{512 << 10, 0},
+	} {
+		files := slowWriter(t, 20, 64<<10).slowSequentialWriters(window, flushWait, c.blockSize)
+		if len(files) != c.affected {
+			t.Errorf("block size %d: %d affected files, want %d", c.blockSize, len(files), c.affected)
+		}
+	}
+}
+
+func TestJudgeWriteFragmentationBlockSize(t *testing.T) {
+	for _, c := range []struct {
+		blockSize int64
+		flushWait string
+	}{
+		// the slowest writer fills a block of 4 MiB in 32s
+		{4 << 20, "--flush-wait=32 "},
+		// it fills 1 MiB in 8s, but the flush wait is at least doubled
+		{1 << 20, "--flush-wait=10 "},
+	} {
+		r := &WriteFragmentationReport{
+			DurationSec:       10,
+			AffectedFiles:     []FileWrites{{Inode: 17669, WriteBytesPerSec: 128 << 10}},
+			CompactionsPerSec: 0.1,
+			CurrentFlushWait:  5,
+			BlockSizeBytes:    c.blockSize,
+		}
+		judgeWriteFragmentation(r, 5*time.Second)
+		if r.Verdict != VerdictLikely || !strings.Contains(r.Recommendation, c.flushWait) {
+			t.Errorf("block size %d: verdict %s, recommendation %q, want %s", c.blockSize, r.Verdict, r.Recommendation, c.flushWait)
+		}
+	}
+}
+
+func TestReadPattern(t *testing.T) {
+	reads := func(offsets ...int64) ReadPattern {
+		a := newReadPatternAnalyzer()
+		for _, offset := range offsets {
+			e, err := ParseAccessLogLine(fmt.Sprintf("2021.01.15 08:26:11.003330 [uid:0,gid:0,pid:4403] read (17665,4096,%d,19): OK <0.000010>", offset))
+			if err != nil {
+				t.Fatal(err)
+			}
+			a.add(e)
+		}
+		return a.result()
+	}
+	sequential := reads(0, 4096, 8192, 12288)
+	if sequential.Reads != 4 || sequential.Handles != 1 || sequential.SequentialReads != 3 || sequential.SequentialRatio != 1 {
+		t.Errorf("sequential pattern = %+v", sequential)
+	}
+	// jumps of 10 MiB, then back to the start
+	random := reads(0, 10<<20, 20<<20, 0)
+	if random.RandomReads != 3 || random.SequentialRatio != 0 || random.MaxJumpBytes != 20<<20+4096 || random.AvgReadBytes != 4096 {
+		t.Errorf("random pattern = %+v", random)
+	}
+}
+
+func TestJudgeReadAmplification(t *testing.T) {
+	random := ReadPattern{Reads: 100, Handles: 2, RandomReads: 90, SequentialReads: 8, SequentialRatio: 0.08, AvgReadBytes: 4096}
+	tests := []struct {
+		name    string
+		report  ReadAmplificationReport
+		verdict string
+	}{
+		{"few reads", ReadAmplificationReport{Pattern: ReadPattern{Reads: 5}}, VerdictInsufficient},
+		{"random small amplified", ReadAmplificationReport{Pattern: random, FuseReadBytesPerSec: 1 << 20, ObjectGetBytesPerSec: 8 << 20}, VerdictLikely},
+		{"random small from cache", ReadAmplificationReport{Pattern: random, FuseReadBytesPerSec: 1 << 20, ObjectGetBytesPerSec: 1 << 20}, VerdictUnlikely},
+		{"sequential", ReadAmplificationReport{Pattern: ReadPattern{Reads: 100, SequentialRatio: 0.9, AvgReadBytes: 4096},
+			FuseReadBytesPerSec: 1 << 20, ObjectGetBytesPerSec: 8 << 20}, VerdictUnlikely},
+	}
+	for _, tt := range tests {
+		r := tt.report
+		judgeReadAmplification(&r)
+		if r.Verdict != tt.verdict {
+			t.Errorf("%s: verdict %s, want %s: %v", tt.name, r.Verdict, tt.verdict, r.Evidence)
+		}
+		if (r.Recommendation != "") != (tt.verdict == VerdictLikely) {
+			t.Errorf("%s: recommendation %q", tt.name, r.Recommendation)
+		}
+	}
+}
@@ -0,0 +1,261 @@
+package juicefs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"k8s.io/apimachinery/pkg/util/json"
+)
+
+type BenchArgs struct {
+	Mountpoint     string `json:"mountpoint" desc:"挂载点" mcp:"required"`
+	BlockSize      int    `json:"blockSize" desc:"每次 IO 的块大小，单位 MiB" mcp:"default=1,min=1,max=64"`
+	BigFileSize    int    `json:"bigFileSize" desc:"大文件的大小，单位 MiB，0 表示不测试大文件" mcp:"default=1024,min=0,max=102400"`
+	SmallFileSize  int    `json:"smallFileSize" desc:"小文件的大小，单位 KiB" mcp:"default=128,min=1,max=65536"`
+	SmallFileCount int    `json:"smallFileCount" desc:"每个线程写入的小文件个数，0 表示不测试小文件" mcp:"default=100,min=0,max=100000"`
+	Threads        int    `json:"threads" desc:"并发线程数" mcp:"default=1,min=1,max=64"`
+	Timeout        int    `json:"timeout" desc:"测试的超时时间，单位秒，0 表示使用服务端配置的命令超时时间" mcp:"default=0,min=0,max=3600"`
+}
+
+// BenchParams are the parameters of a bench run.
+type BenchParams struct {
+	BlockSizeMiB   int `json:"blockSizeMiB"`
+	BigFileSizeMiB int `json:"bigFileSizeMiB"`
+	SmallFileKiB   int `json:"smallFileSizeKiB"`
+	SmallFileCount int `json:"smallFileCount"`
+	Threads        int `json:"threads"`
+}
+
+// requiredBytes is the space taken by the test files, all threads write their own files.
+func (p BenchParams) requiredBytes() uint64 {
+	perThread := uint64(p.BigFileSizeMiB)<<20 + uint64(p.SmallFileKiB)<<10*uint64(p.SmallFileCount)
+	return perThread * uint64(p.Threads)
+}
+
+// args are the flags of `juicefs bench`. The community edition takes sizes with units since 1.1,
+// before that and in the enterprise edition they are numbers, of MiB for the block and the big
+// file and of KiB for the small file.
+func (p BenchParams) args(withUnits bool) []string {
+	block, big, small := strconv.Itoa(p.BlockSizeMiB), strconv.Itoa(p.BigFileSizeMiB), strconv.Itoa(p.SmallFileKiB)
+	if withUnits {
+		block, big, small = block+"M", big+"M", small+"K"
+	}
+	return []string{
+		"--block-size", block,
+		"--big-file-size", big,
+		"--small-file-size", small,
+		"--small-file-count", strconv.Itoa(p.SmallFileCount),
+		"--threads", strconv.Itoa(p.Threads),
+	}
+}
+
+// BenchPhase is a row of the result table of `juicefs bench`, e.g.
+//
+//	|   Write big file |     894.12 MiB/s |  1.15 s/file  |
+type BenchPhase struct {
+	Phase string  `json:"phase"`
+	Value float64 `json:"value"`
+	// Unit is MiB/s or files/s for the file phases and operations for the client ops
+	Unit string `json:"unit"`
+	// LatencyMs is the cost per file or per operation
+	LatencyMs float64 `json:"latencyMs"`
+	Per       string  `json:"per"`
+}
+
+type BenchResult struct {
+	// ID is the ID of the run in the bench history, empty if it's not recorded
+	ID          string       `json:"id,omitempty"`
+	Mountpoint  string       `json:"mountpoint"`
+	Params      BenchParams  `json:"params"`
+	Phases      []BenchPhase `json:"phases"`
+	TimeUsedSec float64      `json:"timeUsedSec"`
+	CPUPercent  float64      `json:"cpuPercent"`
+	MemoryMiB   float64      `json:"memoryMiB"`
+	// Output is the raw output, only kept if no phase is parsed from it
+	Output string `json:"output,omitempty"`
+}
+
+var (
+	ansiEscape = regexp.MustCompile(`\x1b\[[0-9;]*m`)
+	// benchUsage matches the resource usage line, e.g. Time used: 10.9 s, CPU: 96.6%, Memory: 568.6 MiB
+	benchUsage = regexp.MustCompile(`Time used: ([\d.]+) s, CPU: ([\d.]+)%, Memory: ([\d.]+) MiB`)
+)
+
+// ParseBenchOutput parses the result table and the resource usage from the output of `juicefs bench`.
+func ParseBenchOutput(output string) BenchResult {
+	result := BenchResult{Phases: []BenchPhase{}}
+	output = ansiEscape.ReplaceAllString(output, "")
+	// progress bars are redrawn with \r
+	for _, line := range strings.FieldsFunc(output, func(r rune) bool { return r == '\n' || r == '\r' }) {
+		if m := benchUsage.FindStringSubmatch(line); m != nil {
+			result.TimeUsedSec, _ = strconv.ParseFloat(m[1], 64)
+			result.CPUPercent, _ = strconv.ParseFloat(m[2], 64)
+			result.MemoryMiB, _ = strconv.ParseFloat(m[3], 64)
+			continue
+		}
+		if phase, ok := parseBenchRow(line); ok {
+			result.Phases = append(result.Phases, phase)
+		}
+	}
+	return result
+}
+
+var (
+	// legacyBigFile matches e.g. Written a big file (1024.00 MiB): (113.67 MiB/s)
+	legacyBigFile = regexp.MustCompile(`^(Written|Read) a big file \(([\d.]+) MiB\): \(([\d.]+) MiB/s\)`)
+	// legacySmallFiles matches e.g. Written 100 small files (102.40 KiB): (151.7 files/s, 6.6 ms for each file)
+	legacySmallFiles = regexp.MustCompile(`^(Written|Read|Stated) \d+ (?:small )?files.*: \(([\d.]+) files/s, ([\d.]+) ms for each file\)`)
+	// legacyOperation matches e.g. FUSE operation: 19333, avg: 0.3 ms
+	legacyOperation = regexp.MustCompile(`^([A-Z][\w ]+): (\d+), avg: ([\d.]+) ms`)
+	// legacyUsage matches e.g. Used: 23.4s, CPU: 69.1%, MEM: 147.0 MiB
+	legacyUsage = regexp.MustCompile(`^Used: ([\d.]+)s, CPU: ([\d.]+)%, MEM: ([\d.]+) MiB`)
+)
+
+// legacyPhases are the phases of the legacy output by the names of the result table.
+var legacyPhases = map[string]string{
+	"Written big": "Write big file",
+	"Read big":    "Read big file",
+	"Written":     "Write small file",
+	"Read":        "Read small file",
+	"Stated":      "Stat file",
+}
+
+// ParseLegacyBenchOutput parses the output of `juicefs bench` before 1.0 of the community edition,
+// which prints a line per phase instead of a table.
+func ParseLegacyBenchOutput(output string) BenchResult {
+	result := BenchResult{Phases: []BenchPhase{}}
+	output = ansiEscape.ReplaceAllString(output, "")
+	for _, line := range strings.FieldsFunc(output, func(r rune) bool { return r == '\n' || r == '\r' }) {
+		line = strings.TrimSpace(line)
+		if m := legacyBigFile.FindStringSubmatch(line); m != nil {
+			size, _ := strconv.ParseFloat(m[2], 64)
+			phase := BenchPhase{Phase: legacyPhases[m[1]+" big"], Unit: "MiB/s", Per: "file"}
+			phase.Value, _ = strconv.ParseFloat(m[3], 64)
+			if phase.Value > 0 {
+				phase.LatencyMs = size / phase.Value * 1000
+			}
+			result.Phases = append(result.Phases, phase)
+		} else if m := legacySmallFiles.FindStringSubmatch(line); m != nil {
+			phase := BenchPhase{Phase: legacyPhases[m[1]], Unit: "files/s", Per: "file"}
+			phase.Value, _ = strconv.ParseFloat(m[2], 64)
+			phase.LatencyMs, _ = strconv.ParseFloat(m[3], 64)
+			result.Phases = append(result.Phases, phase)
+		} else if m := legacyOperation.FindStringSubmatch(line); m != nil {
+			phase := BenchPhase{Phase: m[1], Unit: "operations", Per: "op"}
+			phase.Value, _ = strconv.ParseFloat(m[2], 64)
+			phase.LatencyMs, _ = strconv.ParseFloat(m[3], 64)
+			result.Phases = append(result.Phases, phase)
+		} else if m := legacyUsage.FindStringSubmatch(line); m != nil {
+			result.TimeUsedSec, _ = strconv.ParseFloat(m[1], 64)
+			result.CPUPercent, _ = strconv.ParseFloat(m[2], 64)
+			result.MemoryMiB, _ = strconv.ParseFloat(m[3], 64)
+		}
+	}
+	return result
+}
+
+// parseBench parses the output of `juicefs bench` in the format of the probed version.
+func (j *JuiceFSHandler) parseBench(output string) BenchResult {
+	if j.version != nil && j.version.Edition == EditionCE && !j.version.AtLeast(1, 0) {
+		return ParseLegacyBenchOutput(output)
+	}
+	return ParseBenchOutput(output)
+}
+
+func parseBenchRow(line string) (BenchPhase, bool) {
+	line = strings.TrimSpace(line)
+	if !strings.HasPrefix(line, "|") {
+		return BenchPhase{}, false
+	}
+	cells := strings.Split(strings.Trim(line, "|"), "|")
+	if len(cells) != 3 {
+		return BenchPhase{}, false
+	}
+	value := strings.Fields(cells[1])
+	cost := strings.Fields(cells[2])
+	if len(value) != 2 || len(cost) != 2 {
+		return BenchPhase{}, false
+	}
+	phase := BenchPhase{Phase: strings.TrimSpace(cells[0]), Unit: value[1]}
+	var err error
+	if phase.Value, err = strconv.ParseFloat(value[0], 64); err != nil {
+		// the header row
+		return BenchPhase{}, false
+	}
+	latency, err := strconv.ParseFloat(cost[0], 64)
+	if err != nil {
+		return BenchPhase{}, false
+	}
+	unit, per, _ := strings.Cut(cost[1], "/")
+	switch unit {
+	case "s":
+		latency *= 1000
+	case "us":
+		latency /= 1000
+	}
+	phase.LatencyMs, phase.Per = latency, per
+	return phase, true
+}
+
+func (j *JuiceFSHandler) handleBench(
+	ctx context.Context,
+	request mcp.CallToolRequest,
+	args BenchArgs,
+) (*mcp.CallToolResult, error) {
+	mountpoint := args.Mountpoint
+	j.log.Debugw("handleBench", "args", args)
+	params := BenchParams{
+		BlockSizeMiB:   args.BlockSize,
+		BigFileSizeMiB: args.BigFileSize,
+		SmallFileKiB:   args.SmallFileSize,
+		SmallFileCount: args.SmallFileCount,
+		Threads:        args.Threads,
+	}
+
+	mount, err := j.mounts.Find(ctx, mountpoint)
+	if err != nil {
+		return nil, fmt.Errorf("bench error: %w", err)
+	}
+	if !mount.Healthy {
+		return nil, fmt.Errorf("bench error: %s is not healthy: %s", mountpoint, mount.StatfsError)
+	}
+	if required := params.requiredBytes(); mount.AvailBytes < required {
+		return nil, fmt.Errorf("bench error: %s has %s free, less than the %s the test needs",
+			mountpoint, humanize(float64(mount.AvailBytes), "B"), humanize(float64(required), "B"))
+	}
+
+	timeout := j.cmdTimeout
+	if args.Timeout > 0 {
+		timeout = time.Second * time.Duration(args.Timeout)
+	}
+	timeoutCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	withUnits := j.version.Edition == EditionCE && j.version.AtLeast(1, 1)
+	cmd := j.exec.CommandContext(timeoutCtx, j.binPath, append([]string{"bench"}, append(params.args(withUnits), mountpoint)...)...)
+	out, err := cmd.CombinedOutput()
+	if errors.Is(timeoutCtx.Err(), context.DeadlineExceeded) {
+		j.log.Errorw("bench timeout", "mountpoint", mountpoint, "timeout", timeout)
+		return nil, fmt.Errorf("bench error: not finished in %s, test files may be left in %s/__juicefs_benchmark_*", timeout, mountpoint)
+	}
+	if err != nil {
+		j.log.Errorw("exec bench error", "mountpoint", mountpoint, "err", err, "output", string(out))
+		return nil, fmt.Errorf("bench error: %w: %s", err, strings.TrimSpace(ansiEscape.ReplaceAllString(string(out), "")))
+	}
+
+	result := j.parseBench(string(out))
+	result.Mountpoint, result.Params = mountpoint, params
+	if len(result.Phases) == 0 {
+		j.log.Warnw("no bench result parsed", "mountpoint", mountpoint)
+		result.Output = string(out)
+	} else if j.benchStore != nil {
+		j.recordBench(ctx, mount, &result)
+	}
+	res, _ := json.Marshal(result)
+	return mcp.NewToolResultText(fmt.Sprintf("%+v", string(res))), nil
+}
@@ -0,0 +1,25 @@
+package juicefs
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestBenchParamsArgs(t *testing.T) {
+	p := BenchParams{BlockSizeMiB: 1, BigFileSizeMiB: 1024, SmallFileKiB: 128, SmallFileCount: 100, Threads: 4}
+	tests := []struct {
+		withUnits bool
+		want      []string
+	}{
+		{true, []string{"--block-size", "1M", "--big-file-size", "1024M", "--small-file-size", "128K", "--small-file-count", "100", "--threads", "4"}},
+		{false, []string{"--block-size", "1", "--big-file-size", "1024", "--small-file-size", "128", "--small-file-count", "100", "--threads", "4"}},
+	}
+	for _, tt := range tests {
+		if got := p.args(tt.withUnits); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("args(%v) = %v, want %v", tt.withUnits, got, tt.want)
+		}
+	}
+	if got, want := p.requiredBytes(), uint64(4*(1024<<20+100*128<<10)); got != want {
+		t.Errorf("requiredBytes = %d, want %d", got, want)
+	}
+}
@@ -0,0 +1,118 @@
+package juicefs
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// sysDevBlock links the block devices by major:minor to their sysfs dirs.
+const sysDevBlock = "/sys/dev/block"
+
+// CacheDisk is a cache dir of a client and the disk under it.
+type CacheDisk struct {
+	// Dir is the cache dir as seen by the client, Path is where the server reaches it
+	Dir  string `json:"dir"`
+	Path string `json:"path"`
+	// Device is the major:minor of the filesystem of the dir
+	Device string `json:"device,omitempty"`
+	// Rotational is whether the disk is a HDD, unknown for virtual devices like overlay
+	Rotational *bool  `json:"rotational,omitempty"`
+	TotalBytes uint64 `json:"totalBytes"`
+	AvailBytes uint64 `json:"availBytes"`
+	Error      string `json:"error,omitempty"`
+}
+
+// cacheDisks inspects the cache dirs of a client. --cache-dir is a colon separated list of dirs which
+// may contain globs, and is resolved within the mount namespace of the client through /proc/<pid>/root.
+func (j *JuiceFSHandler) cacheDisks(ctx context.Context, opts *MountOptions) []CacheDisk {
+	disks := []CacheDisk{}
+	if opts.CacheDir == "memory" {
+		return disks
+	}
+	root := filepath.Join(j.mounts.ProcRoot, strconv.Itoa(opts.PID), "root")
+	for _, dir := range strings.Split(opts.CacheDir, ":") {
+		if dir == "" {
+			continue
+		}
+		paths, err := filepath.Glob(filepath.Join(root, dir))
+		if err != nil || len(paths) == 0 {
+			paths = []string{filepath.Join(root, dir)}
+		}
+		for _, p := range paths {
+			disks = append(disks, j.cacheDisk(ctx, strings.TrimPrefix(p, root), p))
+		}
+	}
+	return disks
+}
+
+func (j *JuiceFSHandler) cacheDisk(ctx context.Context, dir, path string) CacheDisk {
+	disk := CacheDisk{Dir: dir, Path: path}
+	stat, err := statfs(ctx, path, j.mounts.StatfsTimeout)
+	if err != nil {
+		disk.Error = err.Error()
+		return disk
+	}
+	disk.TotalBytes = stat.Blocks * uint64(stat.Bsize)
+	disk.AvailBytes = stat.Bavail * uint64(stat.Bsize)
+	st := &syscall.Stat_t{}
+	if err := syscall.Stat(path, st); err != nil {
+		return disk
+	}
+	// the encoding of dev_t by glibc
+	dev := uint64(st.Dev)
+	major := (dev>>8)&0xfff | (dev>>32)&^0xfff
+	minor := dev&0xff | (dev>>12)&^0xff
+	disk.Device = fmt.Sprintf("%d:%d", major, minor)
+	disk.Rotational = rotational(disk.Device)
+	return disk
+}
+
+// rotational reads queue/rotational of a block device, a partition takes it from its disk.
+func rotational(device string) *bool {
+	dir, err := filepath.EvalSymlinks(filepath.Join(sysDevBlock, device))
+	if err != nil {
+		return nil
+	}
+	for _, d := range []string{dir, filepath.Dir(dir)} {
+		data, err := os.ReadFile(filepath.Join(d, "queue", "rotational"))
+		if err != nil {
+			continue
+		}
+		v := strings.TrimSpace(string(data)) == "1"
+		return &v
+	}
+	return nil
+}
+
+// diskFacts aggregate the cache disks, a client is as slow as its slowest disk and as full as
+// its fullest one.
+func diskFacts(facts Facts, disks []CacheDisk) {
+	var total, avail uint64
+	freeRatio, known := 1.0, 0
+	for _, d := range disks {
+		if d.Error != "" {
+			continue
+		}
+		known++
+		total += d.TotalBytes
+		avail += d.AvailBytes
+		if d.TotalBytes > 0 {
+			freeRatio = min(freeRatio, float64(d.AvailBytes)/float64(d.TotalBytes))
+		}
+		if d.Rotational != nil {
+			facts["disk.rotational"] = *d.Rotational || facts["disk.rotational"] == true
+		}
+	}
+	if known == 0 {
+		return
+	}
+	facts["disk.count"] = float64(known)
+	facts["disk.totalMiB"] = float64(total >> 20)
+	facts["disk.availMiB"] = float64(avail >> 20)
+	facts["disk.freeRatio"] = freeRatio
+}
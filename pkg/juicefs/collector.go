@@ -0,0 +1,273 @@
+package juicefs
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// StatsFile is the hidden file of a mountpoint through which the client exposes its metrics.
+const StatsFile = ".stats"
+
+// series is one line of the .stats file.
+type series struct {
+	name   string
+	labels map[string]string
+	value  float64
+}
+
+// Snapshot is the content of the .stats file at a time.
+type Snapshot struct {
+	Time   time.Time
+	series []series
+}
+
+// ParseSnapshot parses the .stats file in Prometheus text format. Comments are skipped, and the
+// client may flatten label values into metric names, e.g. juicefs_object_request_data_bytes_GET.
+func ParseSnapshot(r io.Reader, t time.Time) (*Snapshot, error) {
+	s := &Snapshot{Time: t}
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		ser, err := parseSeries(line)
+		if err != nil {
+			return nil, fmt.Errorf("parse %q error: %w", line, err)
+		}
+		s.series = append(s.series, ser)
+	}
+	return s, scanner.Err()
+}
+
+func parseSeries(line string) (series, error) {
+	ser := series{labels: map[string]string{}}
+	rest := line
+	if i := strings.IndexByte(line, '{'); i >= 0 {
+		j := strings.LastIndexByte(line, '}')
+		if j < i {
+			return ser, fmt.Errorf("unclosed labels")
+		}
+		ser.name = line[:i]
+		for _, pair := range splitOutsideQuotes(line[i+1 : j]) {
+			key, value, ok := strings.Cut(pair, "=")
+			if !ok {
+				return ser, fmt.Errorf("invalid label %q", pair)
+			}
+			ser.labels[strings.TrimSpace(key)] = strings.Trim(strings.TrimSpace(value), `"`)
+		}
+		rest = line[j+1:]
+	} else {
+		name, value, ok := strings.Cut(line, " ")
+		if !ok {
+			return ser, fmt.Errorf("missing value")
+		}
+		ser.name, rest = name, value
+	}
+	// the value may be followed by a timestamp
+	fields := strings.Fields(rest)
+	if len(fields) == 0 {
+		return ser, fmt.Errorf("missing value")
+	}
+	v, err := strconv.ParseFloat(fields[0], 64)
+	if err != nil {
+		return ser, err
+	}
+	ser.value = v
+	return ser, nil
+}
+
+// Value sums the series of the metric base+suffix. If method is set, only the series of that
+// method are summed, whether it's a label or flattened into the name.
+func (s *Snapshot) Value(base, suffix, method string) float64 {
+	var sum float64
+	for _, ser := range s.series {
+		if !strings.HasPrefix(ser.name, base) || !strings.HasSuffix(ser.name, suffix) || len(ser.name) < len(base)+len(suffix) {
+			continue
+		}
+		middle := ser.name[len(base) : len(ser.name)-len(suffix)]
+		if middle != "" && !strings.HasPrefix(middle, "_") {
+			continue
+		}
+		if method != "" && middle != "_"+method && (middle != "" || ser.labels["method"] != method) {
+			continue
+		}
+		sum += ser.value
+	}
+	return sum
+}
+
+// histogramCount returns the count of a histogram, exposed as _count in Prometheus format
+// and as _total when flattened by the client.
+func (s *Snapshot) histogramCount(base, method string) float64 {
+	return s.Value(base, "_count", method) + s.Value(base, "_total", method)
+}
+
+// buckets returns the cumulative counts of a histogram by upper bound, nil if it has no buckets.
+func (s *Snapshot) buckets(base, method string) map[float64]float64 {
+	var buckets map[float64]float64
+	for _, ser := range s.series {
+		if ser.name != base+"_bucket" || (method != "" && ser.labels["method"] != method) {
+			continue
+		}
+		le, err := strconv.ParseFloat(ser.labels["le"], 64)
+		if err != nil {
+			continue
+		}
+		if buckets == nil {
+			buckets = map[float64]float64{}
+		}
+		buckets[le] += ser.value
+	}
+	return buckets
+}
+
+// ReadSnapshot reads the .stats file of mountpoint, giving up after timeout since the read hangs on
+// a dead FUSE mount. The blocked read is left behind in that case, like statfs.
+func ReadSnapshot(ctx context.Context, mountpoint string, timeout time.Duration) (*Snapshot, error) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	type result struct {
+		snapshot *Snapshot
+		err      error
+	}
+	done := make(chan result, 1)
+	go func() {
+		f, err := os.Open(filepath.Join(mountpoint, StatsFile))
+		if err != nil {
+			done <- result{nil, fmt.Errorf("open stats file error: %w", err)}
+			return
+		}
+		defer f.Close()
+		snapshot, err := ParseSnapshot(f, time.Now())
+		done <- result{snapshot, err}
+	}()
+	select {
+	case <-ctx.Done():
+		return nil, fmt.Errorf("read stats file of %s: no response in %s, the mount may be dead", mountpoint, timeout)
+	case r := <-done:
+		return r.snapshot, r.err
+	}
+}
+
+// CollectSnapshots reads the .stats file of mountpoint count+1 times, interval apart,
+// so that every two adjacent snapshots make a sample. Every read gives up after timeout.
+func CollectSnapshots(ctx context.Context, mountpoint string, count int, interval, timeout time.Duration) ([]*Snapshot, error) {
+	snapshots := make([]*Snapshot, 0, count+1)
+	for i := 0; i <= count; i++ {
+		if i > 0 {
+			select {
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			case <-time.After(interval):
+			}
+		}
+		snapshot, err := ReadSnapshot(ctx, mountpoint, timeout)
+		if err != nil {
+			return nil, err
+		}
+		snapshots = append(snapshots, snapshot)
+	}
+	return snapshots, nil
+}
+
+// checkStatsMount checks that mountpoint is a healthy JuiceFS mount before its .stats file is read,
+// so that a dead mount fails at once instead of leaving blocked reads behind.
+func (j *JuiceFSHandler) checkStatsMount(ctx context.Context, mountpoint string) error {
+	mount, err := j.mounts.Find(ctx, mountpoint)
+	if err != nil {
+		return err
+	}
+	if !mount.Healthy {
+		return fmt.Errorf("%s is not healthy: %s", mountpoint, mount.StatfsError)
+	}
+	return nil
+}
+
+// HistogramStats is a histogram over a window.
+type HistogramStats struct {
+	Name       string  `json:"name"`
+	Count      float64 `json:"count"`
+	RatePerSec float64 `json:"ratePerSec"`
+	AvgMs      float64 `json:"avgMs"`
+	// percentiles are only known if the client exposes buckets
+	P50Ms   *float64 `json:"p50Ms,omitempty"`
+	P95Ms   *float64 `json:"p95Ms,omitempty"`
+	P99Ms   *float64 `json:"p99Ms,omitempty"`
+	Buckets []Bucket `json:"buckets,omitempty"`
+}
+
+// Bucket is the number of observations no larger than LE seconds within the window.
+// The +Inf bucket is left out.
+type Bucket struct {
+	LE    float64 `json:"le"`
+	Count float64 `json:"count"`
+}
+
+// histogram computes the histogram of base (in seconds) between two snapshots.
+func histogram(name, base, method string, from, to *Snapshot) HistogramStats {
+	h := HistogramStats{Name: name}
+	dt := to.Time.Sub(from.Time).Seconds()
+	h.Count = to.histogramCount(base, method) - from.histogramCount(base, method)
+	if dt > 0 {
+		h.RatePerSec = h.Count / dt
+	}
+	if h.Count > 0 {
+		h.AvgMs = (to.Value(base, "_sum", method) - from.Value(base, "_sum", method)) / h.Count * 1000
+	}
+	toBuckets, fromBuckets := to.buckets(base, method), from.buckets(base, method)
+	if toBuckets == nil {
+		return h
+	}
+	for le, count := range toBuckets {
+		h.Buckets = append(h.Buckets, Bucket{LE: le, Count: count - fromBuckets[le]})
+	}
+	sort.Slice(h.Buckets, func(i, k int) bool { return h.Buckets[i].LE < h.Buckets[k].LE })
+	h.P50Ms = quantileMs(0.5, h.Buckets)
+	h.P95Ms = quantileMs(0.95, h.Buckets)
+	h.P99Ms = quantileMs(0.99, h.Buckets)
+	// +Inf can't be encoded in JSON, its count is Count anyway
+	if last := len(h.Buckets) - 1; math.IsInf(h.Buckets[last].LE, 1) {
+		h.Buckets = h.Buckets[:last]
+	}
+	return h
+}
+
+// quantileMs estimates the q quantile in milliseconds from cumulative buckets sorted by upper bound,
+// interpolating linearly within the bucket like histogram_quantile of Prometheus.
+func quantileMs(q float64, buckets []Bucket) *float64 {
+	if len(buckets) == 0 {
+		return nil
+	}
+	total := buckets[len(buckets)-1].Count
+	if total <= 0 {
+		return nil
+	}
+	rank := q * total
+	lowerBound, lowerCount := 0.0, 0.0
+	for _, b := range buckets {
+		if b.Count >= rank {
+			v := b.LE
+			if math.IsInf(b.LE, 1) {
+				// the quantile falls in the +Inf bucket, the best guess is the largest finite bound
+				v = lowerBound
+			} else if b.Count > lowerCount {
+				v = lowerBound + (b.LE-lowerBound)*(rank-lowerCount)/(b.Count-lowerCount)
+			}
+			v *= 1000
+			return &v
+		}
+		lowerBound, lowerCount = b.LE, b.Count
+	}
+	return nil
+}
@@ -2,36 +2,44 @@ package juicefs
 
 import (
 	"context"
+	"fmt"
+	"strings"
 
 	"github.com/mark3labs/mcp-go/mcp"
 )
 
-func (j *JuiceFSHandler) handleDocsOfReadAmplification(
-	ctx context.Context,
-	request mcp.CallToolRequest,
-) (*mcp.CallToolResult, error) {
-	j.log.Debugw("handleDocsOfReadAmplification")
-	return mcp.NewToolResultText("读放大现象是：对象存储的下行流量，远大于实际读文件的速度。通过分析 accesslog，若发现读文件的行为是频繁随机小读。尤其 offset（也就是 read 的第三个参数）跳跃巨大，说明相邻的读操作之间跨度很大，难以利用到预读提前下载下来的数据。建议将 --prefetch 调整为 0，从而禁用预读行为。"), nil
+// DocsURIPrefix is the prefix of the URIs of the tuning docs, e.g. juicefs://docs/data-cache.
+const DocsURIPrefix = "juicefs://docs/"
+
+type doc struct {
+	topic       string
+	name        string
+	description string
+	content     string
 }
 
-func (j *JuiceFSHandler) handleDocsOfWriteAmplification(
-	ctx context.Context,
-	request mcp.CallToolRequest,
-) (*mcp.CallToolResult, error) {
-	j.log.Debugw("handleDocsOfWriteAmplification")
-	return mcp.NewToolResultText(`高频小追加写、随机写带来的写放大是不可避免的，这是 JuiceFS 为了读写性能所做的取舍。但是在低速顺序写场景下的碎片合并问题，我们可以用下方步骤进行甄别和优化：
+var docs = []doc{
+	{
+		topic:       "read-amplification",
+		name:        "JuiceFS 读放大",
+		description: "什么是 JuiceFS 的读放大问题，以及应该如何优化",
+		content:     "读放大现象是：对象存储的下行流量，远大于实际读文件的速度。通过分析 accesslog，若发现读文件的行为是频繁随机小读。尤其 offset（也就是 read 的第三个参数）跳跃巨大，说明相邻的读操作之间跨度很大，难以利用到预读提前下载下来的数据。建议将 --prefetch 调整为 0，从而禁用预读行为。",
+	},
+	{
+		topic:       "write-amplification",
+		name:        "JuiceFS 写放大",
+		description: "什么是 JuiceFS 的写放大问题，以及应该如何优化",
+		content: `高频小追加写、随机写带来的写放大是不可避免的，这是 JuiceFS 为了读写性能所做的取舍。但是在低速顺序写场景下的碎片合并问题，我们可以用下方步骤进行甄别和优化：
 	1. 进行大文件顺序写入时，期望不产生任何碎片，打开文件系统的监控页面，查看对象存储流量面板
 	2. 如果发现碎片合并的流量太大，但是则可能是遇到了写入慢的碎片问题
 	3. 定位到负责写入的 JuiceFS 客户端，调整挂载参数 --flush-wait=60，将默认 5 秒一次的持久化改为 60 秒，能够减少碎片量。
-	`), nil
-}
-
-func (j *JuiceFSHandler) handleDocsOfMetaCache(
-	ctx context.Context,
-	request mcp.CallToolRequest,
-) (*mcp.CallToolResult, error) {
-	j.log.Debugw("handleDocsOfMetaCache")
-	return mcp.NewToolResultText(`
+	`,
+	},
+	{
+		topic:       "meta-cache",
+		name:        "JuiceFS 元数据缓存",
+		description: "元数据缓存的作用，以及如何调优",
+		content: `
 可以通过 FUSE 挂载参数来设置内核元数据缓存时间：
 --attrcacheto=1
 --entrycacheto=1
@@ -47,15 +55,13 @@ entry 缓存是随着文件访问逐渐建立起来的，不是一个完整列
 --metacache # 在客户端中缓存元数据，默认开启
 --metacacheto=300 # 内存元数据的缓存时间，单位为秒，默认 5 分钟
 --max-cached-inodes=500000 # 默认最多会缓存 500000 个 inodes
-	`), nil
-}
-
-func (j *JuiceFSHandler) handleDocsOfDataCache(
-	ctx context.Context,
-	request mcp.CallToolRequest,
-) (*mcp.CallToolResult, error) {
-	j.log.Debugw("handleDocsOfDataCache")
-	return mcp.NewToolResultText(`
+	`,
+	},
+	{
+		topic:       "data-cache",
+		name:        "JuiceFS 数据缓存",
+		description: "数据缓存的作用，以及如何调优，包括内核页缓存、内核回写模式、客户端读缓存、客户端写缓存。",
+		content: `
 JuiceFS 对数据提供多种缓存机制来提高性能，包括内核中的页缓存（Page Cache）和客户端所在机器的本地缓存，以及客户端自身的内存读写缓冲区。读请求会依次尝试内核分页缓存、JuiceFS 进程的预读缓冲区、本地磁盘缓存，当缓存中没找到对应数据时才会从对象存储读取，并且会异步写入各级缓存保证下一次访问的性能。
 
 ## 内核页缓存
@@ -84,15 +90,13 @@ JuiceFS 会默认将所有读取的数据缓存到本地。而在写的时候，
 ## 客户端写缓存
 使用 --writeback 开启客户端写缓存。启用客户端写缓存时，写入流程为「先提交，再异步上传」，数据写入到本地缓存目录并提交到元数据服务后就立即返回，本地缓存目录中的文件数据会在后台异步上传至对象存储。
 由于写缓存的使用注意事项较多，使用不当极易出问题，推荐仅在大量写入小文件时临时开启。
-	`), nil
-}
-
-func (j *JuiceFSHandler) handleDocsOfBufferSize(
-	ctx context.Context,
-	request mcp.CallToolRequest,
-) (*mcp.CallToolResult, error) {
-	j.log.Debugw("handleDocsOfBufferSize")
-	return mcp.NewToolResultText(`
+	`,
+	},
+	{
+		topic:       "buffer-size",
+		name:        "JuiceFS 读写缓冲区",
+		description: "buffer-size 的对读写数据的作用及其调优方法",
+		content: `
 读写缓冲区是分配给 JuiceFS 客户端进程的一块内存，通过 --buffer-size 控制着大小，默认 300（单位 MiB）。读和写产生的数据，都会经过这个缓冲区。
 
 ## 预读与预取
@@ -109,15 +113,13 @@ write 会将数据写入缓冲区，fsync 或者当写入超过块大小（默
 1. --max-uploads 可以增大 block 的上传并发度，同时需要调整 --buffer-size 来使得并发线程更容易申请到内存。
 2. 如果客户端处在一个低带宽的网络环境下，可能需要降低 --buffer-size 来避免 flush 超时。
 3. 希望增加顺序读速度，可以增加 --buffer-size，来放大预读窗口，同时也要增加 --max-downloads 来提升预读的并发度。
-`), nil
-}
-
-func (j *JuiceFSHandler) handleDocsOfReadAheadAndPrefetch(
-	ctx context.Context,
-	request mcp.CallToolRequest,
-) (*mcp.CallToolResult, error) {
-	j.log.Debugw("handleDocsOfReadAheadAndPrefetch")
-	return mcp.NewToolResultText(`
+`,
+	},
+	{
+		topic:       "readahead-prefetch",
+		name:        "JuiceFS 预读和预取",
+		description: "什么是 JuiceFS 的预读和预取",
+		content: `
 ## 预读
 顺序读文件时，JuiceFS 客户端会进行预读（readahead），提前将文件后续的内容下载下来。
 预读窗口大小会根据缓冲区和下载并发度进行推算，在 { buffer-size / 5, block-size * max-downloads, block-size * 128MiB } 中取最小值。
@@ -125,5 +127,42 @@ func (j *JuiceFSHandler) handleDocsOfReadAheadAndPrefetch(
 
 ## 预取
 JuiceFS 还支持预取（prefetch）：读取文件某个块（Block）的一小段时，客户端会异步将整个对象存储块下载下来。但是对于大文件的偏移极大的、稀疏的随机读，prefetch 会带来读放大，可通过 --prefetch=0 禁用该行为。
-`), nil
+`,
+	},
+}
+
+func docURI(topic string) string {
+	return DocsURIPrefix + topic
+}
+
+func findDoc(uri string) (doc, bool) {
+	topic := strings.TrimPrefix(uri, DocsURIPrefix)
+	for _, d := range docs {
+		if d.topic == topic {
+			return d, true
+		}
+	}
+	return doc{}, false
+}
+
+func (j *JuiceFSHandler) handleReadDoc(
+	ctx context.Context,
+	request mcp.ReadResourceRequest,
+) ([]mcp.ResourceContents, error) {
+	j.log.Debugw("handleReadDoc", "uri", request.Params.URI)
+	d, ok := findDoc(request.Params.URI)
+	if !ok {
+		topics := make([]string, 0, len(docs))
+		for _, d := range docs {
+			topics = append(topics, d.topic)
+		}
+		return nil, fmt.Errorf("doc %s not found, available topics: %s", request.Params.URI, strings.Join(topics, ", "))
+	}
+	return []mcp.ResourceContents{
+		mcp.TextResourceContents{
+			URI:      request.Params.URI,
+			MIMEType: "text/markdown",
+			Text:     d.content,
+		},
+	}, nil
 }
@@ -3,9 +3,9 @@ package juicefs
 import (
 	"context"
 	"fmt"
-	"strings"
 
 	"github.com/mark3labs/mcp-go/mcp"
+	"k8s.io/apimachinery/pkg/util/json"
 )
 
 func (j *JuiceFSHandler) handleFindMountPoint(
@@ -13,48 +13,31 @@ func (j *JuiceFSHandler) handleFindMountPoint(
 	request mcp.CallToolRequest,
 ) (*mcp.CallToolResult, error) {
 	j.log.Debugw("handleFindMountPoint", "request", request)
-	cmd := j.exec.CommandContext(ctx, "df")
-	res, err := cmd.CombinedOutput()
+	mounts, err := j.mounts.Discover(ctx)
 	if err != nil {
-		j.log.Errorw("exec df error", "error", err)
-		return nil, fmt.Errorf("exec df error: %w", err)
+		j.log.Errorw("discover mounts error", "error", err)
+		return nil, fmt.Errorf("discover mounts error: %w", err)
 	}
-	results := []string{}
-	mounts := string(res)
-	lines := strings.Split(mounts, "\n")
-	for line := range lines {
-		if strings.Contains(lines[line], "JuiceFS") {
-			results = append(results, lines[line])
-		}
-	}
-	j.log.Debugw("handleFindMountPoint", "results", results)
-	return mcp.NewToolResultText(fmt.Sprintf("%+v", strings.Join(results, "\n"))), nil
+	j.log.Debugw("handleFindMountPoint", "mounts", mounts)
+	res, _ := json.Marshal(mounts)
+	return mcp.NewToolResultText(fmt.Sprintf("%+v", string(res))), nil
+}
+
+type MountpointArgs struct {
+	Mountpoint string `json:"mountpoint" desc:"挂载点" mcp:"required"`
 }
 
 func (j *JuiceFSHandler) handleFindMountOptions(
 	ctx context.Context,
 	request mcp.CallToolRequest,
+	args MountpointArgs,
 ) (*mcp.CallToolResult, error) {
-	j.log.Debugw("handleFindMountOptions", "request", request)
-	mountpoint, ok := request.Params.Arguments["mountpoint"].(string)
-	if !ok {
-		j.log.Errorw("missing mountpoint", "request", request)
-		return nil, fmt.Errorf("missing mountpoint")
-	}
-	cmd := j.exec.CommandContext(ctx, "ps", "-ef")
-	res, err := cmd.CombinedOutput()
+	j.log.Debugw("handleFindMountOptions", "args", args)
+	opts, err := j.mountOptions(ctx, args.Mountpoint)
 	if err != nil {
-		j.log.Errorw("exec ps error", "error", err)
-		return nil, fmt.Errorf("exec ps error: %w", err)
-	}
-	results := []string{}
-	mounts := string(res)
-	lines := strings.Split(mounts, "\n")
-	for line := range lines {
-		if strings.Contains(lines[line], "juicefs") && strings.Contains(lines[line], mountpoint) {
-			results = append(results, lines[line])
-		}
+		return nil, fmt.Errorf("get mount options error: %w", err)
 	}
-	j.log.Debugw("handleFindMountOptions", "results", results)
-	return mcp.NewToolResultText(fmt.Sprintf("%+v", strings.Join(results, "\n"))), nil
+	j.log.Debugw("handleFindMountOptions", "pid", opts.PID, "changed", opts.Changed)
+	res, _ := json.Marshal(opts)
+	return mcp.NewToolResultText(fmt.Sprintf("%+v", string(res))), nil
 }
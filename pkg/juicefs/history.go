@@ -0,0 +1,348 @@
+package juicefs
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"k8s.io/apimachinery/pkg/util/json"
+)
+
+// BenchRecord is a bench run kept in the history.
+type BenchRecord struct {
+	ID         string    `json:"id"`
+	Time       time.Time `json:"time"`
+	Host       string    `json:"host"`
+	Mountpoint string    `json:"mountpoint"`
+	Volume     string    `json:"volume"`
+	// Version is the version of the juicefs binary running the bench
+	Version string `json:"version,omitempty"`
+	// MountOptionsHash identifies the mount options of the client, runs are only comparable
+	// with the same options
+	MountOptionsHash string      `json:"mountOptionsHash"`
+	Result           BenchResult `json:"result"`
+}
+
+// BenchStore keeps the bench runs as one JSON file per run under Dir.
+type BenchStore struct {
+	Dir string
+}
+
+func NewBenchStore(dataDir string) *BenchStore {
+	return &BenchStore{Dir: filepath.Join(dataDir, "bench")}
+}
+
+// Save assigns an ID to the record and writes it.
+func (s *BenchStore) Save(r *BenchRecord) error {
+	if err := os.MkdirAll(s.Dir, 0o755); err != nil {
+		return err
+	}
+	suffix := make([]byte, 3)
+	_, _ = rand.Read(suffix)
+	r.ID = r.Time.UTC().Format("20060102-150405") + "-" + hex.EncodeToString(suffix)
+	data, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	// written to a temp file and renamed, so a crash never leaves a partial record
+	tmp := filepath.Join(s.Dir, "."+r.ID+".tmp")
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, filepath.Join(s.Dir, r.ID+".json"))
+}
+
+// Get reads the record of id.
+func (s *BenchStore) Get(id string) (*BenchRecord, error) {
+	if id == "" || strings.ContainsAny(id, `/\`) || strings.HasPrefix(id, ".") {
+		return nil, fmt.Errorf("invalid bench run id %q", id)
+	}
+	data, err := os.ReadFile(filepath.Join(s.Dir, id+".json"))
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("bench run %s not found", id)
+	}
+	if err != nil {
+		return nil, err
+	}
+	r := &BenchRecord{}
+	if err := json.Unmarshal(data, r); err != nil {
+		return nil, fmt.Errorf("parse bench run %s error: %w", id, err)
+	}
+	return r, nil
+}
+
+// List returns the records, the latest first. Unreadable records are skipped.
+func (s *BenchStore) List() ([]BenchRecord, error) {
+	files, err := filepath.Glob(filepath.Join(s.Dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	records := []BenchRecord{}
+	for _, file := range files {
+		r, err := s.Get(strings.TrimSuffix(filepath.Base(file), ".json"))
+		if err != nil {
+			continue
+		}
+		records = append(records, *r)
+	}
+	sort.Slice(records, func(i, j int) bool { return records[i].Time.After(records[j].Time) })
+	return records, nil
+}
+
+// hashMountOptions hashes the options that affect performance, leaving out the PID and mountpoint
+// which change on every mount.
+func hashMountOptions(opts *MountOptions) string {
+	o := *opts
+	o.PID, o.Mountpoint, o.Changed = 0, "", nil
+	data, _ := json.Marshal(o)
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:6])
+}
+
+// recordBench saves a bench run to the history, the run is returned anyway if it fails.
+func (j *JuiceFSHandler) recordBench(ctx context.Context, mount *Mount, result *BenchResult) {
+	record := &BenchRecord{
+		Time:       time.Now(),
+		Mountpoint: mount.Mountpoint,
+		Volume:     mount.Volume,
+		Result:     *result,
+	}
+	record.Host, _ = os.Hostname()
+	if j.version != nil {
+		record.Version = j.version.Edition + "-" + j.version.Version
+	}
+	if opts, err := j.mountOptions(ctx, mount.Mountpoint); err == nil {
+		record.MountOptionsHash = hashMountOptions(opts)
+	} else {
+		j.log.Warnw("get mount options of bench error", "mountpoint", mount.Mountpoint, "error", err)
+	}
+	if err := j.benchStore.Save(record); err != nil {
+		j.log.Errorw("save bench run error", "mountpoint", mount.Mountpoint, "error", err)
+		return
+	}
+	result.ID = record.ID
+}
+
+// benchMetric is a metric compared between runs, higher values are better unless lowerBetter.
+type benchMetric struct {
+	name        string
+	unit        string
+	lowerBetter bool
+	value       float64
+}
+
+// benchMetrics are the throughput and latency of each phase and the total time of a run.
+// The operation counts of the client depend on the parameters rather than performance, and are left out.
+func benchMetrics(r BenchResult) []benchMetric {
+	metrics := []benchMetric{}
+	for _, p := range r.Phases {
+		if p.Unit != "operations" {
+			metrics = append(metrics, benchMetric{p.Phase, p.Unit, false, p.Value})
+		}
+		metrics = append(metrics, benchMetric{p.Phase + " latency", "ms/" + p.Per, true, p.LatencyMs})
+	}
+	if r.TimeUsedSec > 0 {
+		metrics = append(metrics, benchMetric{"time used", "s", true, r.TimeUsedSec})
+	}
+	return metrics
+}
+
+// MetricComparison compares a metric of a run with the same metric of the baseline runs.
+type MetricComparison struct {
+	Metric         string  `json:"metric"`
+	Unit           string  `json:"unit"`
+	LowerIsBetter  bool    `json:"lowerIsBetter"`
+	Value          float64 `json:"value"`
+	BaselineMean   float64 `json:"baselineMean"`
+	BaselineStdDev float64 `json:"baselineStdDev"`
+	BaselineRuns   int     `json:"baselineRuns"`
+	ChangePercent  float64 `json:"changePercent"`
+	// TScore is the distance to the baseline mean in units of the prediction error, only known
+	// with 2 baseline runs or more
+	TScore *float64 `json:"tScore,omitempty"`
+	// Significant is whether the change is out of the 95% prediction interval of the baseline,
+	// with a single baseline run it's whether the change exceeds the threshold
+	Significant bool `json:"significant"`
+	Regression  bool `json:"regression"`
+	Improvement bool `json:"improvement"`
+}
+
+type BenchComparison struct {
+	Run         BenchRecord        `json:"run"`
+	Baseline    []string           `json:"baseline"`
+	Metrics     []MetricComparison `json:"metrics"`
+	Regressions []string           `json:"regressions"`
+	// Warnings tell why the runs may not be comparable
+	Warnings []string `json:"warnings"`
+}
+
+// tCritical95 are the one-sided 95% critical values of Student's t distribution by degrees of freedom.
+var tCritical95 = []float64{0, 6.314, 2.920, 2.353, 2.132, 2.015, 1.943, 1.895, 1.860, 1.833, 1.812,
+	1.796, 1.782, 1.771, 1.761, 1.753, 1.746, 1.740, 1.734, 1.729, 1.725,
+	1.721, 1.717, 1.714, 1.711, 1.708, 1.706, 1.703, 1.701, 1.699, 1.697}
+
+func tCritical(df int) float64 {
+	if df < len(tCritical95) {
+		return tCritical95[df]
+	}
+	return 1.645
+}
+
+// compareBench compares every metric of run with the baseline runs. A change is a regression if it's
+// worse than the baseline mean by minChange percent and out of its 95% prediction interval.
+func compareBench(run BenchRecord, baseline []BenchRecord, minChange float64) BenchComparison {
+	c := BenchComparison{Run: run, Baseline: []string{}, Metrics: []MetricComparison{}, Regressions: []string{}, Warnings: []string{}}
+	values := map[string][]float64{}
+	for _, b := range baseline {
+		c.Baseline = append(c.Baseline, b.ID)
+		for _, m := range benchMetrics(b.Result) {
+			values[m.name] = append(values[m.name], m.value)
+		}
+		if b.Result.Params != run.Result.Params {
+			c.Warnings = append(c.Warnings, fmt.Sprintf("baseline %s has different parameters %+v", b.ID, b.Result.Params))
+		}
+		if b.MountOptionsHash != run.MountOptionsHash {
+			c.Warnings = append(c.Warnings, fmt.Sprintf("baseline %s has different mount options", b.ID))
+		}
+		if b.Version != run.Version {
+			c.Warnings = append(c.Warnings, fmt.Sprintf("baseline %s ran with juicefs %s", b.ID, b.Version))
+		}
+		if b.Host != run.Host {
+			c.Warnings = append(c.Warnings, fmt.Sprintf("baseline %s ran on host %s", b.ID, b.Host))
+		}
+	}
+	for _, m := range benchMetrics(run.Result) {
+		vs := values[m.name]
+		if len(vs) == 0 {
+			continue
+		}
+		mc := MetricComparison{Metric: m.name, Unit: m.unit, LowerIsBetter: m.lowerBetter, Value: m.value, BaselineRuns: len(vs)}
+		for _, v := range vs {
+			mc.BaselineMean += v
+		}
+		mc.BaselineMean /= float64(len(vs))
+		if mc.BaselineMean != 0 {
+			mc.ChangePercent = (m.value - mc.BaselineMean) / mc.BaselineMean * 100
+		}
+		exceeds := math.Abs(mc.ChangePercent) >= minChange
+		mc.Significant = exceeds
+		if n := len(vs); n >= 2 {
+			for _, v := range vs {
+				mc.BaselineStdDev += (v - mc.BaselineMean) * (v - mc.BaselineMean)
+			}
+			mc.BaselineStdDev = math.Sqrt(mc.BaselineStdDev / float64(n-1))
+			if se := mc.BaselineStdDev * math.Sqrt(1+1/float64(n)); se > 0 {
+				t := (m.value - mc.BaselineMean) / se
+				mc.TScore = &t
+				mc.Significant = exceeds && math.Abs(t) > tCritical(n-1)
+			}
+		}
+		worse := (m.value < mc.BaselineMean) != m.lowerBetter && m.value != mc.BaselineMean
+		mc.Regression = mc.Significant && worse
+		mc.Improvement = mc.Significant && !worse
+		if mc.Regression {
+			c.Regressions = append(c.Regressions, m.name)
+		}
+		c.Metrics = append(c.Metrics, mc)
+	}
+	return c
+}
+
+type BenchHistoryArgs struct {
+	Mountpoint string `json:"mountpoint" desc:"只返回该挂载点的测试记录，为空则返回全部"`
+	Limit      int    `json:"limit" desc:"返回的最大记录数，按时间倒序" mcp:"default=20,min=1,max=1000"`
+}
+
+func (j *JuiceFSHandler) handleBenchHistory(
+	ctx context.Context,
+	request mcp.CallToolRequest,
+	args BenchHistoryArgs,
+) (*mcp.CallToolResult, error) {
+	j.log.Debugw("handleBenchHistory", "args", args)
+	records, err := j.benchStore.List()
+	if err != nil {
+		return nil, fmt.Errorf("list bench history error: %w", err)
+	}
+	matched := []BenchRecord{}
+	for _, r := range records {
+		if args.Mountpoint == "" || r.Mountpoint == filepath.Clean(args.Mountpoint) {
+			matched = append(matched, r)
+		}
+	}
+	if len(matched) > args.Limit {
+		matched = matched[:args.Limit]
+	}
+	res, _ := json.Marshal(matched)
+	return mcp.NewToolResultText(fmt.Sprintf("%+v", string(res))), nil
+}
+
+type CompareBenchArgs struct {
+	Run      string   `json:"run" desc:"要对比的测试记录 ID，为空则使用最新的一次"`
+	Baseline []string `json:"baseline" desc:"作为基线的测试记录 ID，为空则使用同一挂载点、同样测试参数和挂载参数的之前几次测试"`
+	// BaselineRuns only applies when Baseline is empty
+	BaselineRuns int     `json:"baselineRuns" desc:"自动选择基线时使用的最近测试次数" mcp:"default=5,min=1,max=100"`
+	MinChange    float64 `json:"minChange" desc:"判定为回退的最小变化幅度，单位百分比" mcp:"default=10,min=0,max=100"`
+}
+
+func (j *JuiceFSHandler) handleCompareBench(
+	ctx context.Context,
+	request mcp.CallToolRequest,
+	args CompareBenchArgs,
+) (*mcp.CallToolResult, error) {
+	j.log.Debugw("handleCompareBench", "args", args)
+	records, err := j.benchStore.List()
+	if err != nil {
+		return nil, fmt.Errorf("compare bench error: %w", err)
+	}
+	var run *BenchRecord
+	for i := range records {
+		if args.Run == "" || records[i].ID == args.Run {
+			run = &records[i]
+			break
+		}
+	}
+	if run == nil {
+		if args.Run == "" {
+			return nil, fmt.Errorf("compare bench error: no bench run in history")
+		}
+		return nil, fmt.Errorf("compare bench error: bench run %s not found", args.Run)
+	}
+
+	baseline := []BenchRecord{}
+	if len(args.Baseline) > 0 {
+		for _, id := range args.Baseline {
+			r, err := j.benchStore.Get(id)
+			if err != nil {
+				return nil, fmt.Errorf("compare bench error: %w", err)
+			}
+			baseline = append(baseline, *r)
+		}
+	} else {
+		// records are sorted by time, the latest first
+		for _, r := range records {
+			if len(baseline) >= args.BaselineRuns {
+				break
+			}
+			if r.Time.Before(run.Time) && r.Mountpoint == run.Mountpoint && r.Host == run.Host &&
+				r.Result.Params == run.Result.Params && r.MountOptionsHash == run.MountOptionsHash {
+				baseline = append(baseline, r)
+			}
+		}
+		if len(baseline) == 0 {
+			return nil, fmt.Errorf("compare bench error: no earlier run of %s with the same parameters and mount options, set the baseline explicitly", run.Mountpoint)
+		}
+	}
+	comparison := compareBench(*run, baseline, args.MinChange)
+	j.log.Debugw("compare bench", "run", run.ID, "baseline", comparison.Baseline, "regressions", comparison.Regressions)
+	res, _ := json.Marshal(comparison)
+	return mcp.NewToolResultText(fmt.Sprintf("%+v", string(res))), nil
+}
@@ -0,0 +1,71 @@
+package juicefs
+
+import "testing"
+
+// benchRun returns a run writing big files at throughput MiB/s.
+func benchRun(id string, throughput float64) BenchRecord {
+	return BenchRecord{ID: id, Host: "node-1", Result: BenchResult{
+		Phases: []BenchPhase{{Phase: "Write big file", Value: throughput, Unit: "MiB/s", LatencyMs: 1, Per: "MiB"}},
+	}}
+}
+
+func TestCompareBench(t *testing.T) {
+	tests := []struct {
+		name        string
+		baseline    []float64
+		run         float64
+		regression  bool
+		improvement bool
+		tScore      bool
+	}{
+		// a single run has no spread, the change is judged by the threshold alone
+		{"single baseline regressed", []float64{100}, 80, true, false, false},
+		{"single baseline within threshold", []float64{100}, 95, false, false, false},
+		{"single baseline improved", []float64{100}, 130, false, true, false},
+		{"steady baseline regressed", []float64{100, 102, 98}, 80, true, false, true},
+		{"steady baseline improved", []float64{100, 102, 98}, 120, false, true, true},
+		// the change exceeds the threshold but not the spread of the baseline
+		{"noisy baseline inconclusive", []float64{60, 100, 140}, 80, false, false, true},
+		// identical runs have no spread either
+		{"identical baseline regressed", []float64{100, 100}, 80, true, false, false},
+	}
+	for _, tt := range tests {
+		baseline := []BenchRecord{}
+		for _, v := range tt.baseline {
+			baseline = append(baseline, benchRun("base", v))
+		}
+		c := compareBench(benchRun("run", tt.run), baseline, 10)
+		var m *MetricComparison
+		for i := range c.Metrics {
+			if c.Metrics[i].Metric == "Write big file" {
+				m = &c.Metrics[i]
+			}
+		}
+		if m == nil {
+			t.Errorf("%s: throughput not compared: %+v", tt.name, c.Metrics)
+			continue
+		}
+		if m.BaselineRuns != len(tt.baseline) || m.Regression != tt.regression || m.Improvement != tt.improvement || (m.TScore != nil) != tt.tScore {
+			t.Errorf("%s: comparison = %+v", tt.name, *m)
+		}
+		if regressed := len(c.Regressions) == 1 && c.Regressions[0] == "Write big file"; regressed != tt.regression {
+			t.Errorf("%s: regressions = %v", tt.name, c.Regressions)
+		}
+		if len(c.Warnings) != 0 {
+			t.Errorf("%s: warnings = %v", tt.name, c.Warnings)
+		}
+	}
+}
+
+func TestCompareBenchNoBaseline(t *testing.T) {
+	c := compareBench(benchRun("run", 100), nil, 10)
+	if len(c.Metrics) != 0 || len(c.Regressions) != 0 || len(c.Baseline) != 0 {
+		t.Errorf("comparison without baseline = %+v", c)
+	}
+	// runs that may not be comparable are compared anyway with a warning
+	other := benchRun("base", 100)
+	other.Host = "node-2"
+	if c := compareBench(benchRun("run", 100), []BenchRecord{other}, 10); len(c.Warnings) != 1 || len(c.Metrics) == 0 {
+		t.Errorf("comparison with another host = %+v", c)
+	}
+}
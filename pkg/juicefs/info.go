@@ -0,0 +1,272 @@
+package juicefs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"k8s.io/apimachinery/pkg/util/json"
+)
+
+// PathInfo is the output of `juicefs info` for a file or directory.
+type PathInfo struct {
+	Path       string `json:"path"`
+	Mountpoint string `json:"mountpoint"`
+	// FSPath is the path within the file system
+	FSPath string `json:"fsPath"`
+	Inode  uint64 `json:"inode"`
+	Type   string `json:"type"`
+	// Files, Dirs and Size are recursive for directories
+	Files  int64 `json:"files"`
+	Dirs   int64 `json:"dirs"`
+	Length int64 `json:"length"`
+	Size   int64 `json:"size"`
+	// Chunks are the layout of a file, chunks beyond MaxObjects objects are left out
+	Chunks           []ChunkLayout  `json:"chunks,omitempty"`
+	Objects          int            `json:"objects,omitempty"`
+	ObjectsTruncated bool           `json:"objectsTruncated,omitempty"`
+	Fragmentation    *Fragmentation `json:"fragmentation,omitempty"`
+}
+
+// ChunkLayout is a chunk (64M) of a file and the slices it's made of, in the order the file reads
+// them. A slice shows up again when another one overwrites its middle.
+type ChunkLayout struct {
+	Index  int           `json:"index"`
+	Slices []SliceLayout `json:"slices"`
+}
+
+// SliceLayout is a slice written at once, stored as blocks of up to the block size.
+type SliceLayout struct {
+	ID     uint64        `json:"id"`
+	Blocks []BlockObject `json:"blocks"`
+}
+
+// BlockObject is a block of a slice in the object storage. Offset and Length are the part of it
+// the file reads, the rest is overwritten by later slices.
+type BlockObject struct {
+	Key    string `json:"key"`
+	Index  int    `json:"index"`
+	Size   int64  `json:"size"`
+	Offset int64  `json:"offset"`
+	Length int64  `json:"length"`
+}
+
+// Fragmentation is how many slices a chunk is made of, a file written sequentially at once has
+// one slice per chunk. Reads of fragmented chunks go to more objects until they are compacted.
+type Fragmentation struct {
+	Chunks            int     `json:"chunks"`
+	Slices            int     `json:"slices"`
+	Score             float64 `json:"score"`
+	MaxSlicesPerChunk int     `json:"maxSlicesPerChunk"`
+	MaxChunk          int     `json:"maxChunk"`
+}
+
+// objectKey matches the name of a block object, <prefix>/chunks/<id/1M>/<id/1K>/<slice id>_<block index>_<block size>.
+var objectKey = regexp.MustCompile(`(\d+)_(\d+)_(\d+)$`)
+
+// ParseInfoOutput parses the output of `juicefs info`, e.g.
+//
+//	/jfs/file :
+//	  inode: 2
+//	  files: 1
+//	   dirs: 0
+//	 length: 1.00 GiB (1073741824 Bytes)
+//	   size: 1.00 GiB (1073741824 Bytes)
+//	   path: /file
+//	 objects:
+//	+------------+------------------------------+---------+--------+---------+
+//	| chunkIndex |          objectName          |   size  | offset |  length |
+//	+------------+------------------------------+---------+--------+---------+
+//	|          0 | myjfs/chunks/0/0/1_0_4194304 | 4194304 |      0 | 4194304 |
+//	+------------+------------------------------+---------+--------+---------+
+func ParseInfoOutput(output string, maxObjects int) (*PathInfo, error) {
+	info := &PathInfo{}
+	chunks := map[int]*ChunkLayout{}
+	slices := map[int]map[uint64]bool{}
+	parsed := false
+	for _, line := range strings.Split(ansiEscape.ReplaceAllString(output, ""), "\n") {
+		line = strings.TrimSpace(line)
+		if strings.HasPrefix(line, "|") {
+			if addObjectRow(info, line, chunks, slices, maxObjects) {
+				parsed = true
+			}
+			continue
+		}
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		value = strings.TrimSpace(value)
+		var err error
+		switch strings.TrimSpace(key) {
+		case "inode":
+			info.Inode, err = strconv.ParseUint(value, 10, 64)
+			parsed = true
+		case "files":
+			info.Files, err = strconv.ParseInt(value, 10, 64)
+		case "dirs":
+			info.Dirs, err = strconv.ParseInt(value, 10, 64)
+		case "length":
+			info.Length, err = parseInfoBytes(value)
+		case "size":
+			info.Size, err = parseInfoBytes(value)
+		case "path":
+			info.FSPath = value
+		}
+		if err != nil {
+			return nil, fmt.Errorf("parse %q error: %w", line, err)
+		}
+	}
+	if !parsed {
+		return nil, fmt.Errorf("unknown info output: %s", strings.TrimSpace(output))
+	}
+	if len(slices) > 0 {
+		info.Fragmentation = fragmentation(slices)
+	}
+	for _, chunk := range chunks {
+		info.Chunks = append(info.Chunks, *chunk)
+	}
+	sort.Slice(info.Chunks, func(i, j int) bool { return info.Chunks[i].Index < info.Chunks[j].Index })
+	return info, nil
+}
+
+// addObjectRow adds a row of the objects table, it returns false for the header and other tables.
+// All objects count for the fragmentation, only the first maxObjects are kept in the layout.
+func addObjectRow(info *PathInfo, line string, chunks map[int]*ChunkLayout, slices map[int]map[uint64]bool, maxObjects int) bool {
+	cells := strings.Split(strings.Trim(line, "|"), "|")
+	if len(cells) != 5 {
+		return false
+	}
+	for i := range cells {
+		cells[i] = strings.TrimSpace(cells[i])
+	}
+	index, err := strconv.Atoi(cells[0])
+	if err != nil {
+		return false
+	}
+	m := objectKey.FindStringSubmatch(cells[1])
+	if m == nil {
+		// a hole of the file has no object
+		return true
+	}
+	block := BlockObject{Key: cells[1]}
+	sliceID, _ := strconv.ParseUint(m[1], 10, 64)
+	block.Index, _ = strconv.Atoi(m[2])
+	block.Size, _ = strconv.ParseInt(cells[2], 10, 64)
+	block.Offset, _ = strconv.ParseInt(cells[3], 10, 64)
+	block.Length, _ = strconv.ParseInt(cells[4], 10, 64)
+
+	if slices[index] == nil {
+		slices[index] = map[uint64]bool{}
+	}
+	slices[index][sliceID] = true
+	info.Objects++
+	if info.Objects > maxObjects {
+		info.ObjectsTruncated = true
+		return true
+	}
+	chunk := chunks[index]
+	if chunk == nil {
+		chunk = &ChunkLayout{Index: index, Slices: []SliceLayout{}}
+		chunks[index] = chunk
+	}
+	// the blocks of a slice are listed together
+	if n := len(chunk.Slices); n == 0 || chunk.Slices[n-1].ID != sliceID {
+		chunk.Slices = append(chunk.Slices, SliceLayout{ID: sliceID})
+	}
+	last := &chunk.Slices[len(chunk.Slices)-1]
+	last.Blocks = append(last.Blocks, block)
+	return true
+}
+
+func fragmentation(slices map[int]map[uint64]bool) *Fragmentation {
+	f := &Fragmentation{Chunks: len(slices), MaxChunk: -1}
+	for index, chunk := range slices {
+		f.Slices += len(chunk)
+		if len(chunk) > f.MaxSlicesPerChunk || len(chunk) == f.MaxSlicesPerChunk && index < f.MaxChunk {
+			f.MaxSlicesPerChunk, f.MaxChunk = len(chunk), index
+		}
+	}
+	f.Score = float64(f.Slices) / float64(f.Chunks)
+	return f
+}
+
+// infoBytes matches the exact bytes of a size like 1.00 GiB (1073741824 Bytes).
+var infoBytes = regexp.MustCompile(`\((\d+) Bytes\)`)
+
+// parseInfoBytes parses a size printed by `juicefs info`, the exact bytes are preferred over
+// the human readable size which some versions print alone.
+func parseInfoBytes(value string) (int64, error) {
+	if m := infoBytes.FindStringSubmatch(value); m != nil {
+		return strconv.ParseInt(m[1], 10, 64)
+	}
+	number, unit, _ := strings.Cut(value, " ")
+	v, err := strconv.ParseFloat(number, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid size %q", value)
+	}
+	shift := 0
+	if unit = strings.TrimSpace(unit); unit != "" {
+		shift = strings.IndexByte("BKMGTP", unit[0])
+	}
+	if shift < 0 {
+		return 0, fmt.Errorf("invalid size %q", value)
+	}
+	return int64(v * float64(uint64(1)<<(10*shift))), nil
+}
+
+type InfoArgs struct {
+	Path       string `json:"path" desc:"JuiceFS 挂载点下的文件或目录的绝对路径" mcp:"required"`
+	MaxObjects int    `json:"maxObjects" desc:"文件返回的对象个数上限，碎片统计不受影响" mcp:"default=1000,min=1,max=100000"`
+}
+
+func (j *JuiceFSHandler) handleInfo(
+	ctx context.Context,
+	request mcp.CallToolRequest,
+	args InfoArgs,
+) (*mcp.CallToolResult, error) {
+	j.log.Debugw("handleInfo", "args", args)
+	path, mount, err := j.mounts.ResolvedMountOf(ctx, args.Path)
+	if err != nil {
+		return nil, fmt.Errorf("info error: %w", err)
+	}
+	if !mount.Healthy {
+		return nil, fmt.Errorf("info error: %s is not healthy: %s", mount.Mountpoint, mount.StatfsError)
+	}
+	stat, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("info error: %w", err)
+	}
+
+	cmdArgs := []string{"info"}
+	if stat.IsDir() {
+		cmdArgs = append(cmdArgs, "--recursive")
+	}
+	timeoutCtx, cancel := context.WithTimeout(ctx, j.cmdTimeout)
+	defer cancel()
+	out, err := j.exec.CommandContext(timeoutCtx, j.binPath, append(cmdArgs, path)...).CombinedOutput()
+	if errors.Is(timeoutCtx.Err(), context.DeadlineExceeded) {
+		return nil, fmt.Errorf("info error: not finished in %s", j.cmdTimeout)
+	}
+	if err != nil {
+		j.log.Errorw("exec info error", "path", path, "err", err, "output", string(out))
+		return nil, fmt.Errorf("info error: %w: %s", err, strings.TrimSpace(string(out)))
+	}
+	info, err := ParseInfoOutput(string(out), args.MaxObjects)
+	if err != nil {
+		return nil, fmt.Errorf("info error: %w", err)
+	}
+	info.Path, info.Mountpoint = path, mount.Mountpoint
+	info.Type = "file"
+	if stat.IsDir() {
+		info.Type = "directory"
+	}
+	res, _ := json.Marshal(info)
+	return mcp.NewToolResultText(fmt.Sprintf("%+v", string(res))), nil
+}
@@ -0,0 +1,98 @@
+package juicefs
+
+import (
+	"os"
+	"reflect"
+	"testing"
+)
+
+func readInfoOutput(t *testing.T, file string, maxObjects int) *PathInfo {
+	out, err := os.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	info, err := ParseInfoOutput(string(out), maxObjects)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return info
+}
+
+func TestParseInfoOutputFile(t *testing.T) {
+	info := readInfoOutput(t, "testdata/info_file", 1000)
+	if info.Inode != 17665 || info.FSPath != "/data/file" || info.Files != 1 || info.Length != 75497472 || info.Size != 75497472 {
+		t.Errorf("unexpected info %+v", info)
+	}
+	if info.Objects != 5 || info.ObjectsTruncated {
+		t.Errorf("objects %d truncated %v, want 5 objects", info.Objects, info.ObjectsTruncated)
+	}
+	var slices [][]uint64
+	for _, chunk := range info.Chunks {
+		ids := []uint64{}
+		for _, slice := range chunk.Slices {
+			ids = append(ids, slice.ID)
+		}
+		slices = append(slices, ids)
+	}
+	// slice 5 overwrites the middle of slice 1, the hole of chunk 1 has no object
+	if want := [][]uint64{{1, 5, 1}, {7}}; !reflect.DeepEqual(slices, want) {
+		t.Errorf("slices of chunks = %v, want %v", slices, want)
+	}
+	first := info.Chunks[0].Slices[0]
+	want := []BlockObject{
+		{Key: "myjfs/chunks/0/0/1_0_4194304", Index: 0, Size: 4194304, Offset: 0, Length: 4194304},
+		{Key: "myjfs/chunks/0/0/1_1_4194304", Index: 1, Size: 4194304, Offset: 0, Length: 1048576},
+	}
+	if !reflect.DeepEqual(first.Blocks, want) {
+		t.Errorf("blocks of slice 1 = %+v, want %+v", first.Blocks, want)
+	}
+	if f := info.Fragmentation; f == nil || *f != (Fragmentation{Chunks: 2, Slices: 3, Score: 1.5, MaxSlicesPerChunk: 2, MaxChunk: 0}) {
+		t.Errorf("fragmentation = %+v", f)
+	}
+}
+
+func TestParseInfoOutputMaxObjects(t *testing.T) {
+	info := readInfoOutput(t, "testdata/info_file", 3)
+	if info.Objects != 5 || !info.ObjectsTruncated {
+		t.Errorf("objects %d truncated %v, want 5 truncated", info.Objects, info.ObjectsTruncated)
+	}
+	if len(info.Chunks) != 1 || len(info.Chunks[0].Slices) != 2 {
+		t.Errorf("chunks beyond 3 objects kept: %+v", info.Chunks)
+	}
+	// the fragmentation counts all objects
+	if f := info.Fragmentation; f == nil || f.Slices != 3 {
+		t.Errorf("fragmentation = %+v", f)
+	}
+}
+
+func TestParseInfoOutputDir(t *testing.T) {
+	info := readInfoOutput(t, "testdata/info_dir", 1000)
+	if info.Inode != 17660 || info.Files != 1234 || info.Dirs != 56 || info.Length != 11274289152 || info.Size != 11381663744 || info.FSPath != "/data" {
+		t.Errorf("unexpected info %+v", info)
+	}
+	if info.Chunks != nil || info.Fragmentation != nil {
+		t.Errorf("directory has a layout: %+v", info)
+	}
+	if _, err := ParseInfoOutput("no such file or directory", 10); err == nil {
+		t.Error("unknown output parsed")
+	}
+}
+
+func TestParseInfoBytes(t *testing.T) {
+	for value, want := range map[string]int64{
+		"1.00 GiB (1073741824 Bytes)": 1073741824,
+		"1.50 KiB":                    1536,
+		"4.00 MiB":                    4 << 20,
+		"100 Bytes":                   100,
+		"0":                           0,
+	} {
+		if got, err := parseInfoBytes(value); err != nil || got != want {
+			t.Errorf("parseInfoBytes(%q) = %d, %v, want %d", value, got, err, want)
+		}
+	}
+	for _, value := range []string{"", "abc", "1 XiB"} {
+		if _, err := parseInfoBytes(value); err == nil {
+			t.Errorf("parseInfoBytes(%q) succeeded", value)
+		}
+	}
+}
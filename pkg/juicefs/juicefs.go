@@ -3,11 +3,10 @@ package juicefs
 import (
 	"context"
 	"fmt"
-	"path/filepath"
-	"strings"
 	"time"
 
 	"github.com/mark3labs/mcp-go/mcp"
+	"k8s.io/apimachinery/pkg/util/json"
 )
 
 func (j *JuiceFSHandler) getJuiceFSWorkflow(
@@ -17,82 +16,75 @@ func (j *JuiceFSHandler) getJuiceFSWorkflow(
 	j.log.Debugw("get juicefs workflow")
 	return mcp.NewToolResultText(`
 对于 JuiceFS 的任何性能问题，可以遵循以下步骤：
-1. 需要先获取到 JuiceFS 的 mountpoint，使用 tool find_mountpoint;
-2. 通过 mountpoint 来进行性能测试，使用 tool bench_in_juicefs;
+1. 需要先获取到 JuiceFS 的 mountpoint，使用 tool juicefs_find_mountpoint;
+2. 通过 mountpoint 来进行性能测试，使用 tool juicefs_bench_in_juicefs;
 `), nil
 }
 
-func (j *JuiceFSHandler) handleBench(
-	ctx context.Context,
-	request mcp.CallToolRequest,
-) (*mcp.CallToolResult, error) {
-	mountpoint, ok := request.Params.Arguments["mountpoint"].(string)
-	if !ok {
-		j.log.Errorw("missing mountpoint", "request", request)
-		return nil, fmt.Errorf("missing mountpoint")
-	}
-	j.log.Debugw("handleBench", "mountpoint", mountpoint)
-
-	cmd := j.exec.CommandContext(ctx, "juicefs", "bench", mountpoint)
-	res, err := cmd.CombinedOutput()
-	if err != nil {
-		j.log.Errorw("exec bench error", "mountpoint", mountpoint, "err", err)
-		return nil, fmt.Errorf("bench error: %w", err)
-	}
-	fmt.Println(string(res))
-	return mcp.NewToolResultText(fmt.Sprintf("%+v", string(res))), nil
+type StatsArgs struct {
+	Mountpoint string `json:"mountpoint" desc:"挂载点" mcp:"required"`
+	Samples    int    `json:"samples" desc:"采样次数" mcp:"default=5,min=1,max=120"`
+	Interval   int    `json:"interval" desc:"采样间隔，单位秒" mcp:"default=1,min=1,max=60"`
 }
 
 func (j *JuiceFSHandler) handleStats(
 	ctx context.Context,
 	request mcp.CallToolRequest,
+	args StatsArgs,
 ) (*mcp.CallToolResult, error) {
-	mountpoint, ok := request.Params.Arguments["mountpoint"].(string)
-	if !ok {
-		j.log.Errorw("missing mountpoint", "request", request)
-		return nil, fmt.Errorf("missing mountpoint")
-	}
-	interval, ok := request.Params.Arguments["interval"].(int)
-	if !ok {
-		interval = 3
-	}
-	j.log.Debugw("handleStats", "mountpoint", mountpoint)
-	timeoutCtx, cancel := context.WithTimeout(ctx, time.Second*time.Duration(interval))
-	defer cancel()
+	mountpoint, samples, interval := args.Mountpoint, args.Samples, args.Interval
+	j.log.Debugw("handleStats", "args", args)
 
-	cmd := j.exec.CommandContext(timeoutCtx, "juicefs", "stats", mountpoint, "-l", "1")
-	res, err := cmd.CombinedOutput()
-	if err != nil && !strings.Contains(err.Error(), "signal: killed") {
-		j.log.Errorw("exec juicefs stats error", "mountpoint", mountpoint, "err", err, "res", string(res))
+	if err := j.checkStatsMount(ctx, mountpoint); err != nil {
 		return nil, fmt.Errorf("stats error: %w", err)
 	}
-	fmt.Println(string(res))
-	return mcp.NewToolResultText(fmt.Sprintf("%+v", string(res))), nil
+	snapshots, err := CollectSnapshots(ctx, mountpoint, samples, time.Second*time.Duration(interval), j.mounts.StatfsTimeout)
+	if err != nil {
+		j.log.Errorw("collect stats error", "mountpoint", mountpoint, "err", err)
+		return nil, fmt.Errorf("stats error: %w", err)
+	}
+	result := collectStats(mountpoint, snapshots)
+	result.Interval = interval
+	res, _ := json.Marshal(result)
+	return &mcp.CallToolResult{
+		Content: []mcp.Content{
+			mcp.NewTextContent(string(res)),
+			mcp.NewTextContent(fmt.Sprintf("%d samples every %ds of %s:\n%s", len(result.Samples), interval, mountpoint, formatSummary(result.Summary))),
+		},
+	}, nil
+}
+
+type AccessLogArgs struct {
+	Mountpoint   string   `json:"mountpoint" desc:"挂载点" mcp:"required"`
+	Interval     int      `json:"interval" desc:"采集时长，单位秒" mcp:"default=3,min=1,max=60"`
+	TopN         int      `json:"topN" desc:"返回访问最多的 inode、路径和进程的个数" mcp:"default=10,min=1,max=100"`
+	Ops          []string `json:"ops" desc:"只统计这些操作，如 read、write、lookup，为空则统计全部"`
+	Inode        int64    `json:"inode" desc:"只统计该 inode 的操作，0 表示不过滤" mcp:"default=0,min=0"`
+	Pid          int      `json:"pid" desc:"只统计该进程的操作，0 表示不过滤" mcp:"default=0,min=0"`
+	IncludeLines bool     `json:"includeLines" desc:"是否返回原始日志行" mcp:"default=false"`
+	MaxLines     int      `json:"maxLines" desc:"返回原始日志行的最大行数" mcp:"default=100,min=1,max=10000"`
 }
 
 func (j *JuiceFSHandler) handleAccessLog(
 	ctx context.Context,
 	request mcp.CallToolRequest,
+	args AccessLogArgs,
 ) (*mcp.CallToolResult, error) {
-	mountpoint, ok := request.Params.Arguments["mountpoint"].(string)
-	if !ok {
-		j.log.Errorw("missing mountpoint", "request", request)
-		return nil, fmt.Errorf("missing mountpoint")
-	}
-	interval, ok := request.Params.Arguments["interval"].(int)
-	if !ok {
-		interval = 3
-	}
-	j.log.Debugw("handleStats", "mountpoint", mountpoint)
-	timeoutCtx, cancel := context.WithTimeout(ctx, time.Second*time.Duration(interval))
-	defer cancel()
+	mountpoint, interval := args.Mountpoint, args.Interval
+	j.log.Debugw("handleAccessLog", "args", args)
 
-	cmd := j.exec.CommandContext(timeoutCtx, "cat", filepath.Join(mountpoint, ".accesslog"))
-	res, err := cmd.CombinedOutput()
-	if err != nil && !strings.Contains(err.Error(), "signal: killed") {
-		j.log.Errorw("exec cat accesslog error", "mountpoint", mountpoint, "err", err)
+	maxLines := 0
+	if args.IncludeLines {
+		maxLines = args.MaxLines
+	}
+	analyzer := newAccessLogAnalyzer(AccessLogFilter{Ops: args.Ops, Inode: uint64(args.Inode), PID: args.Pid}, maxLines)
+	unparsed, err := ReadAccessLog(ctx, mountpoint, time.Second*time.Duration(interval), analyzer.add)
+	if err != nil {
+		j.log.Errorw("read accesslog error", "mountpoint", mountpoint, "err", err)
 		return nil, fmt.Errorf("accesslog error: %w", err)
 	}
-	fmt.Println(string(res))
+	report := analyzer.report(args.TopN)
+	report.Mountpoint, report.DurationSec, report.Unparsed = mountpoint, interval, unparsed
+	res, _ := json.Marshal(report)
 	return mcp.NewToolResultText(fmt.Sprintf("%+v", string(res))), nil
 }
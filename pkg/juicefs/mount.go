@@ -0,0 +1,417 @@
+package juicefs
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+const (
+	// DefaultMountInfoPath lists the mounts seen by the server.
+	DefaultMountInfoPath = "/proc/self/mountinfo"
+	DefaultProcRoot      = "/proc"
+	// FSType is the filesystem type of JuiceFS mounts in mountinfo.
+	FSType = "fuse.juicefs"
+
+	defaultStatfsTimeout = 2 * time.Second
+	fuseDevice           = "/dev/fuse"
+)
+
+// Mount is a JuiceFS mount.
+type Mount struct {
+	MountID    int    `json:"mountId"`
+	Mountpoint string `json:"mountpoint"`
+	// Volume is the volume name, taken from the mount source JuiceFS:<volume>
+	Volume       string `json:"volume"`
+	Source       string `json:"source"`
+	Device       string `json:"device"`
+	Options      string `json:"options"`
+	SuperOptions string `json:"superOptions"`
+	// FuseConnection is the FUSE connection of the mount in /sys/fs/fuse/connections, the minor of Device
+	FuseConnection int `json:"fuseConnection"`
+	// PID is the client process serving the mount, 0 if it's not found
+	PID int `json:"pid,omitempty"`
+	// Healthy is whether statfs on the mountpoint returns in time
+	Healthy     bool   `json:"healthy"`
+	StatfsError string `json:"statfsError,omitempty"`
+	TotalBytes  uint64 `json:"totalBytes,omitempty"`
+	AvailBytes  uint64 `json:"availBytes,omitempty"`
+}
+
+// MountDiscoverer discovers the JuiceFS mounts from mountinfo. The paths can be pointed
+// at fixtures.
+type MountDiscoverer struct {
+	MountInfoPath string
+	ProcRoot      string
+	StatfsTimeout time.Duration
+
+	lock sync.Mutex
+	// statfsCalls are the statfs calls in flight by mountpoint, a call blocked on a dead mount
+	// stays here until it returns, so it's never issued twice
+	statfsCalls map[string]*statfsCall
+}
+
+type statfsCall struct {
+	started time.Time
+	done    chan struct{}
+	stat    *syscall.Statfs_t
+	err     error
+}
+
+func NewMountDiscoverer() *MountDiscoverer {
+	return &MountDiscoverer{
+		MountInfoPath: DefaultMountInfoPath,
+		ProcRoot:      DefaultProcRoot,
+		StatfsTimeout: defaultStatfsTimeout,
+		statfsCalls:   map[string]*statfsCall{},
+	}
+}
+
+// ParseMountInfo parses the JuiceFS mounts in mountinfo, whose lines are like
+//
+//	36 25 0:52 / /jfs rw,relatime shared:1 - fuse.juicefs JuiceFS:myjfs rw,user_id=0,group_id=0,default_permissions,allow_other
+func ParseMountInfo(r io.Reader) ([]Mount, error) {
+	mounts := []Mount{}
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		sep := -1
+		for i, field := range fields {
+			if field == "-" {
+				sep = i
+				break
+			}
+		}
+		if sep < 6 || len(fields) < sep+3 {
+			continue
+		}
+		if fields[sep+1] != FSType {
+			continue
+		}
+		id, err := strconv.Atoi(fields[0])
+		if err != nil {
+			return nil, fmt.Errorf("invalid mount id %q", fields[0])
+		}
+		m := Mount{
+			MountID:    id,
+			Device:     fields[2],
+			Mountpoint: unescapeMountInfo(fields[4]),
+			Options:    fields[5],
+			Source:     unescapeMountInfo(fields[sep+2]),
+		}
+		if len(fields) > sep+3 {
+			m.SuperOptions = fields[sep+3]
+		}
+		m.Volume = strings.TrimPrefix(m.Source, "JuiceFS:")
+		if _, minor, ok := strings.Cut(m.Device, ":"); ok {
+			m.FuseConnection, _ = strconv.Atoi(minor)
+		}
+		mounts = append(mounts, m)
+	}
+	return mounts, scanner.Err()
+}
+
+// unescapeMountInfo decodes the octal escapes of mountinfo, e.g. \040 for space.
+func unescapeMountInfo(s string) string {
+	if !strings.Contains(s, `\`) {
+		return s
+	}
+	buf := &strings.Builder{}
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+3 < len(s) {
+			if v, err := strconv.ParseUint(s[i+1:i+4], 8, 8); err == nil {
+				buf.WriteByte(byte(v))
+				i += 3
+				continue
+			}
+		}
+		buf.WriteByte(s[i])
+	}
+	return buf.String()
+}
+
+// list lists the JuiceFS mounts with their client PIDs, but not their health.
+func (d *MountDiscoverer) list() ([]Mount, error) {
+	f, err := os.Open(d.MountInfoPath)
+	if err != nil {
+		return nil, fmt.Errorf("open mountinfo error: %w", err)
+	}
+	defer f.Close()
+	mounts, err := ParseMountInfo(f)
+	if err != nil {
+		return nil, fmt.Errorf("parse mountinfo error: %w", err)
+	}
+	clients := d.clientsByDevice(d.fuseClients())
+	for i := range mounts {
+		mounts[i].PID = clients[mounts[i].Device]
+	}
+	return mounts, nil
+}
+
+// Discover lists the JuiceFS mounts with their client PIDs and health, the mounts are checked
+// in parallel so that dead ones don't hold up the others.
+func (d *MountDiscoverer) Discover(ctx context.Context) ([]Mount, error) {
+	mounts, err := d.list()
+	if err != nil {
+		return nil, err
+	}
+	wg := sync.WaitGroup{}
+	for i := range mounts {
+		wg.Add(1)
+		go func(m *Mount) {
+			defer wg.Done()
+			d.checkHealth(ctx, m)
+		}(&mounts[i])
+	}
+	wg.Wait()
+	return mounts, nil
+}
+
+func (d *MountDiscoverer) checkHealth(ctx context.Context, m *Mount) {
+	stat, err := d.statfs(ctx, m.Mountpoint)
+	if err != nil {
+		m.StatfsError = err.Error()
+		return
+	}
+	m.Healthy = true
+	m.TotalBytes = stat.Blocks * uint64(stat.Bsize)
+	m.AvailBytes = stat.Bavail * uint64(stat.Bsize)
+}
+
+// statfs runs statfs on the mountpoint. A mount whose last call is still blocked after the
+// timeout is known dead and fails at once, without another call left behind.
+func (d *MountDiscoverer) statfs(ctx context.Context, mountpoint string) (*syscall.Statfs_t, error) {
+	d.lock.Lock()
+	call, ok := d.statfsCalls[mountpoint]
+	if !ok {
+		call = &statfsCall{started: time.Now(), done: make(chan struct{})}
+		d.statfsCalls[mountpoint] = call
+		go func() {
+			stat := &syscall.Statfs_t{}
+			call.err = syscall.Statfs(mountpoint, stat)
+			call.stat = stat
+			close(call.done)
+			d.lock.Lock()
+			delete(d.statfsCalls, mountpoint)
+			d.lock.Unlock()
+		}()
+	}
+	d.lock.Unlock()
+
+	wait := d.StatfsTimeout - time.Since(call.started)
+	if wait <= 0 {
+		select {
+		case <-call.done:
+			return call.stat, call.err
+		default:
+			return nil, fmt.Errorf("statfs %s: blocked for %s, the mount may be dead", mountpoint, time.Since(call.started).Truncate(time.Second))
+		}
+	}
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-call.done:
+		return call.stat, call.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-timer.C:
+		return nil, fmt.Errorf("statfs %s: no response in %s, the mount may be dead", mountpoint, d.StatfsTimeout)
+	}
+}
+
+// Find returns the mount of mountpoint, only its health is checked.
+func (d *MountDiscoverer) Find(ctx context.Context, mountpoint string) (*Mount, error) {
+	mounts, err := d.list()
+	if err != nil {
+		return nil, err
+	}
+	mountpoint = filepath.Clean(mountpoint)
+	for i := range mounts {
+		if mounts[i].Mountpoint == mountpoint {
+			d.checkHealth(ctx, &mounts[i])
+			return &mounts[i], nil
+		}
+	}
+	return nil, fmt.Errorf("%s is not a JuiceFS mountpoint", mountpoint)
+}
+
+// MountOf returns the mount that path is under, path must be absolute. Only the health of the
+// mount is checked.
+func (d *MountDiscoverer) MountOf(ctx context.Context, path string) (*Mount, error) {
+	if !filepath.IsAbs(path) {
+		return nil, fmt.Errorf("%s is not an absolute path", path)
+	}
+	mounts, err := d.list()
+	if err != nil {
+		return nil, err
+	}
+	path = filepath.Clean(path)
+	var found *Mount
+	for i := range mounts {
+		m := &mounts[i]
+		rel, err := filepath.Rel(m.Mountpoint, path)
+		if err != nil || rel == ".." || strings.HasPrefix(rel, "../") {
+			continue
+		}
+		// the innermost mount wins if mounts are nested
+		if found == nil || len(m.Mountpoint) > len(found.Mountpoint) {
+			found = m
+		}
+	}
+	if found == nil {
+		return nil, fmt.Errorf("%s is not under a JuiceFS mountpoint", path)
+	}
+	d.checkHealth(ctx, found)
+	return found, nil
+}
+
+// ResolvedMountOf returns path with its symlinks resolved and the mount it is under, so that a link
+// can't lead out of the mount. The symlinks are only resolved in a healthy mount, since that hangs
+// on a dead one, the unresolved path is returned with an unhealthy mount.
+func (d *MountDiscoverer) ResolvedMountOf(ctx context.Context, path string) (string, *Mount, error) {
+	m, err := d.MountOf(ctx, path)
+	if err != nil || !m.Healthy {
+		return path, m, err
+	}
+	resolved, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		return "", nil, err
+	}
+	m, err = d.MountOf(ctx, resolved)
+	if err != nil {
+		return "", nil, err
+	}
+	return resolved, m, nil
+}
+
+// fuseClient is a process holding a FUSE connection open.
+type fuseClient struct {
+	pid  int
+	args []string
+}
+
+// fuseClients lists the processes holding /dev/fuse open, which serve the FUSE connections.
+// A juicefs mount supervisor shares the command line of its client, but doesn't hold the connection.
+func (d *MountDiscoverer) fuseClients() []fuseClient {
+	entries, err := os.ReadDir(d.ProcRoot)
+	if err != nil {
+		return nil
+	}
+	clients := []fuseClient{}
+	for _, entry := range entries {
+		pid, err := strconv.Atoi(entry.Name())
+		if err != nil {
+			continue
+		}
+		procDir := filepath.Join(d.ProcRoot, entry.Name())
+		if !holdsFuse(procDir) {
+			continue
+		}
+		cmdline, err := os.ReadFile(filepath.Join(procDir, "cmdline"))
+		if err != nil {
+			continue
+		}
+		clients = append(clients, fuseClient{pid: pid, args: splitClientCmdline(cmdline)})
+	}
+	return clients
+}
+
+func holdsFuse(procDir string) bool {
+	fds, err := os.ReadDir(filepath.Join(procDir, "fd"))
+	if err != nil {
+		return false
+	}
+	for _, fd := range fds {
+		if target, err := os.Readlink(filepath.Join(procDir, "fd", fd.Name())); err == nil && target == fuseDevice {
+			return true
+		}
+	}
+	return false
+}
+
+// splitCmdline splits the NUL separated /proc/<pid>/cmdline.
+func splitCmdline(cmdline []byte) []string {
+	args := []string{}
+	for _, arg := range bytes.Split(bytes.TrimRight(cmdline, "\x00"), []byte{0}) {
+		args = append(args, string(arg))
+	}
+	return args
+}
+
+// splitClientCmdline splits the cmdline of a juicefs client. A client hiding the password of its
+// meta URL rewrites its process title, its cmdline is then a single string of the args joined by
+// spaces, with the password replaced by maskedPassword.
+func splitClientCmdline(cmdline []byte) []string {
+	args := splitCmdline(cmdline)
+	if len(args) == 1 && strings.ContainsAny(args[0], " \t") {
+		return strings.Fields(args[0])
+	}
+	return args
+}
+
+// clientsByDevice maps the device of each FUSE connection to the client serving it. A client is
+// matched in its own mount namespace, where its mountpoint argument is the mount it created, e.g.
+// the path in a CSI mount pod. The device is the same wherever the mount is seen or bind mounted,
+// so the mounts of the server are matched by device.
+func (d *MountDiscoverer) clientsByDevice(clients []fuseClient) map[string]int {
+	devices := map[string]int{}
+	for _, c := range clients {
+		f, err := os.Open(filepath.Join(d.ProcRoot, strconv.Itoa(c.pid), "mountinfo"))
+		if err != nil {
+			continue
+		}
+		mounts, err := ParseMountInfo(f)
+		f.Close()
+		if err != nil {
+			continue
+		}
+		for _, m := range mounts {
+			if c.serves(m.Mountpoint) {
+				devices[m.Device] = c.pid
+			}
+		}
+	}
+	return devices
+}
+
+// serves returns whether mountpoint is an argument of the client.
+func (c fuseClient) serves(mountpoint string) bool {
+	for _, arg := range c.args[min(1, len(c.args)):] {
+		if !strings.HasPrefix(arg, "-") && filepath.Clean(arg) == mountpoint {
+			return true
+		}
+	}
+	return false
+}
+
+// statfs runs statfs on path, giving up after timeout since it hangs on a dead FUSE mount.
+// The blocked call is left behind in that case, mounts go through MountDiscoverer.statfs instead.
+func statfs(ctx context.Context, path string, timeout time.Duration) (*syscall.Statfs_t, error) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	type result struct {
+		stat *syscall.Statfs_t
+		err  error
+	}
+	done := make(chan result, 1)
+	go func() {
+		stat := &syscall.Statfs_t{}
+		err := syscall.Statfs(path, stat)
+		done <- result{stat, err}
+	}()
+	select {
+	case <-ctx.Done():
+		return nil, fmt.Errorf("statfs %s: no response in %s, the mount may be dead", path, timeout)
+	case r := <-done:
+		return r.stat, r.err
+	}
+}
@@ -0,0 +1,249 @@
+package juicefs
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseMountInfo(t *testing.T) {
+	f, err := os.Open("testdata/mountinfo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	mounts, err := ParseMountInfo(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []struct {
+		id         int
+		mountpoint string
+		volume     string
+		device     string
+		connection int
+	}{
+		{36, "/jfs", "myjfs", "0:52", 52},
+		{37, "/mnt/my data", "other", "0:53", 53},
+		{38, "/var/lib/kubelet/pods/uid/volumes/kubernetes.io~csi/pvc/mount", "myjfs", "0:52", 52},
+	}
+	if len(mounts) != len(want) {
+		t.Fatalf("got %d mounts, want %d: %+v", len(mounts), len(want), mounts)
+	}
+	for i, w := range want {
+		m := mounts[i]
+		if m.MountID != w.id || m.Mountpoint != w.mountpoint || m.Volume != w.volume || m.Device != w.device || m.FuseConnection != w.connection {
+			t.Errorf("mount %d = %+v, want %+v", i, m, w)
+		}
+	}
+}
+
+func TestUnescapeMountInfo(t *testing.T) {
+	for in, want := range map[string]string{
+		`/mnt/my\040data`:   "/mnt/my data",
+		`/mnt/tab\011x`:     "/mnt/tab\tx",
+		`/mnt/back\134sl`:   `/mnt/back\sl`,
+		`/mnt/plain`:        "/mnt/plain",
+		`/mnt/trailing\04`:  `/mnt/trailing\04`,
+		`/mnt/not\999octal`: `/mnt/not\999octal`,
+	} {
+		if got := unescapeMountInfo(in); got != want {
+			t.Errorf("unescapeMountInfo(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+// fakeProc builds a proc root with processes of the given cmdlines and mountinfo, holding /dev/fuse
+// open if fuse is set. The cmdline is written as a rewritten process title if title is set.
+func fakeProc(t *testing.T, procs map[int]struct {
+	cmdline   string
+	mountinfo string
+	fuse      bool
+	title     bool
+}) string {
+	root := t.TempDir()
+	for pid, p := range procs {
+		dir := filepath.Join(root, fmt.Sprint(pid))
+		if err := os.MkdirAll(filepath.Join(dir, "fd"), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		cmdline := strings.ReplaceAll(p.cmdline, " ", "\x00") + "\x00"
+		if p.title {
+			cmdline = p.cmdline + "\x00\x00\x00"
+		}
+		if err := os.WriteFile(filepath.Join(dir, "cmdline"), []byte(cmdline), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, "mountinfo"), []byte(p.mountinfo), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Symlink("/dev/null", filepath.Join(dir, "fd", "0")); err != nil {
+			t.Fatal(err)
+		}
+		if p.fuse {
+			if err := os.Symlink(fuseDevice, filepath.Join(dir, "fd", "3")); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	return root
+}
+
+func TestDiscoverClients(t *testing.T) {
+	hostMountInfo, err := os.ReadFile("testdata/mountinfo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	d := NewMountDiscoverer()
+	d.MountInfoPath = "testdata/mountinfo"
+	d.StatfsTimeout = 100 * time.Millisecond
+	d.ProcRoot = fakeProc(t, map[int]struct {
+		cmdline   string
+		mountinfo string
+		fuse      bool
+		title     bool
+	}{
+		// a client on the host and its supervisor, which doesn't hold the connection
+		100: {"juicefs mount -d redis://h/1 /jfs", string(hostMountInfo), true, false},
+		101: {"juicefs mount -d redis://h/1 /jfs", string(hostMountInfo), false, false},
+		// a client in a CSI mount pod, its mount is at another path in its own mount namespace
+		200: {"/usr/local/bin/juicefs mount redis://h/2 /jfs/pvc-abc -o cache-size=1024",
+			"50 40 0:53 / /jfs/pvc-abc rw,relatime - fuse.juicefs JuiceFS:other rw,user_id=0\n", true, false},
+		// a FUSE client of another filesystem
+		300: {"sshfs host:/ /sshfs", string(hostMountInfo), true, false},
+	})
+
+	mounts, err := d.Discover(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]int{
+		"/jfs":         100,
+		"/mnt/my data": 200,
+		// a bind mount of a subdir is served by the same connection
+		"/var/lib/kubelet/pods/uid/volumes/kubernetes.io~csi/pvc/mount": 100,
+	}
+	for _, m := range mounts {
+		if m.PID != want[m.Mountpoint] {
+			t.Errorf("PID of %s = %d, want %d", m.Mountpoint, m.PID, want[m.Mountpoint])
+		}
+		// the fixture mountpoints don't exist here
+		if m.Healthy || m.StatfsError == "" {
+			t.Errorf("%s is healthy", m.Mountpoint)
+		}
+	}
+
+	m, err := d.MountOf(context.Background(), "/mnt/my data/dir/file")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Mountpoint != "/mnt/my data" || m.PID != 200 {
+		t.Errorf("MountOf = %+v", m)
+	}
+	if _, err := d.MountOf(context.Background(), "/sshfs/x"); err == nil {
+		t.Error("path in a non-JuiceFS mount accepted")
+	}
+	if _, err := d.Find(context.Background(), "/jfs/sub"); err == nil {
+		t.Error("path under a mountpoint found as a mountpoint")
+	}
+}
+
+func TestDiscoverRewrittenProcTitle(t *testing.T) {
+	hostMountInfo, err := os.ReadFile("testdata/mountinfo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	d := NewMountDiscoverer()
+	d.MountInfoPath = "testdata/mountinfo"
+	d.StatfsTimeout = 100 * time.Millisecond
+	// a client hiding its meta password has its args joined by spaces in one string
+	d.ProcRoot = fakeProc(t, map[int]struct {
+		cmdline   string
+		mountinfo string
+		fuse      bool
+		title     bool
+	}{
+		100: {"juicefs mount -d redis://:****@h/1 /jfs", string(hostMountInfo), true, true},
+	})
+
+	m, err := d.Find(context.Background(), "/jfs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.PID != 100 {
+		t.Errorf("PID of /jfs = %d, want 100", m.PID)
+	}
+}
+
+func TestDiscoverHealth(t *testing.T) {
+	dir := t.TempDir()
+	mountinfo := filepath.Join(t.TempDir(), "mountinfo")
+	line := fmt.Sprintf("36 22 0:52 / %s rw,relatime - fuse.juicefs JuiceFS:myjfs rw\n", dir)
+	if err := os.WriteFile(mountinfo, []byte(line), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	d := NewMountDiscoverer()
+	d.MountInfoPath = mountinfo
+	d.ProcRoot = t.TempDir()
+	m, err := d.Find(context.Background(), dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !m.Healthy || m.TotalBytes == 0 {
+		t.Errorf("mount of a local dir is not healthy: %+v", m)
+	}
+}
+
+func TestResolvedMountOf(t *testing.T) {
+	dir, outside := t.TempDir(), t.TempDir()
+	mountinfo := filepath.Join(t.TempDir(), "mountinfo")
+	line := fmt.Sprintf("36 22 0:52 / %s rw,relatime - fuse.juicefs JuiceFS:myjfs rw\n", dir)
+	if err := os.WriteFile(mountinfo, []byte(line), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Mkdir(filepath.Join(dir, "data"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink("data", filepath.Join(dir, "inside")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(outside, filepath.Join(dir, "outside")); err != nil {
+		t.Fatal(err)
+	}
+	d := NewMountDiscoverer()
+	d.MountInfoPath = mountinfo
+	d.ProcRoot = t.TempDir()
+
+	path, m, err := d.ResolvedMountOf(context.Background(), filepath.Join(dir, "inside"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want, _ := filepath.EvalSymlinks(filepath.Join(dir, "data")); path != want || !m.Healthy {
+		t.Errorf("ResolvedMountOf = %s, %+v, want %s", path, m, want)
+	}
+	// a link out of the mount is not under it
+	if _, _, err := d.ResolvedMountOf(context.Background(), filepath.Join(dir, "outside")); err == nil {
+		t.Error("link out of the mount accepted")
+	}
+}
+
+func TestStatfsKnownDead(t *testing.T) {
+	d := NewMountDiscoverer()
+	d.StatfsTimeout = 10 * time.Millisecond
+	// a call still in flight past the timeout stands for a dead mount
+	d.statfsCalls["/dead"] = &statfsCall{started: time.Now().Add(-time.Minute), done: make(chan struct{})}
+	start := time.Now()
+	if _, err := d.statfs(context.Background(), "/dead"); err == nil {
+		t.Error("dead mount reported healthy")
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Millisecond {
+		t.Errorf("known dead mount waited %s", elapsed)
+	}
+	if len(d.statfsCalls) != 1 {
+		t.Errorf("another statfs call was issued: %d in flight", len(d.statfsCalls))
+	}
+}
@@ -0,0 +1,360 @@
+package juicefs
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"juicefs-mcp/pkg/utils"
+)
+
+// MountOptions are the options a client mounts with. Sizes are in MiB, limits in Mbps
+// and times in seconds, like the flags of `juicefs mount`.
+type MountOptions struct {
+	PID        int    `json:"pid"`
+	MetaURL    string `json:"metaURL"`
+	Mountpoint string `json:"mountpoint"`
+
+	CacheDir         string  `json:"cacheDir"`
+	CacheSize        int64   `json:"cacheSize"`
+	FreeSpaceRatio   float64 `json:"freeSpaceRatio"`
+	CachePartialOnly bool    `json:"cachePartialOnly"`
+	BufferSize       int64   `json:"bufferSize"`
+	Prefetch         int64   `json:"prefetch"`
+	Writeback        bool    `json:"writeback"`
+	FlushWait        float64 `json:"flushWait"`
+	UploadLimit      int64   `json:"uploadLimit"`
+	DownloadLimit    int64   `json:"downloadLimit"`
+	MaxUploads       int64   `json:"maxUploads"`
+	AttrCache        float64 `json:"attrCache"`
+	EntryCache       float64 `json:"entryCache"`
+	DirEntryCache    float64 `json:"dirEntryCache"`
+	OpenCache        float64 `json:"openCache"`
+	ReadOnly         bool    `json:"readOnly"`
+	SubDir           string  `json:"subDir"`
+	// FuseOptions are the other options passed by -o, e.g. writeback_cache, sensitive values are redacted
+	FuseOptions []string `json:"fuseOptions"`
+	// Other are the flags not known, sensitive values are redacted
+	Other map[string]string `json:"other"`
+	// Changed are the options set to values other than the defaults
+	Changed []ChangedOption `json:"changed"`
+}
+
+type ChangedOption struct {
+	Name    string `json:"name"`
+	Value   any    `json:"value"`
+	Default any    `json:"default"`
+}
+
+type flagKind int
+
+const (
+	kindString flagKind = iota
+	kindBool
+	kindInt
+	kindFloat
+	// kindSeconds is a number of seconds or a duration like 1.5s
+	kindSeconds
+	// kindMiB is a number of MiB or a size with a unit like 100G
+	kindMiB
+)
+
+// mountFlag is a flag of `juicefs mount`, names are its aliases in both editions.
+type mountFlag struct {
+	names []string
+	kind  flagKind
+	def   string
+	field func(o *MountOptions) any
+}
+
+var mountFlags = []mountFlag{
+	{[]string{"cache-dir"}, kindString, "/var/jfsCache", func(o *MountOptions) any { return &o.CacheDir }},
+	{[]string{"cache-size"}, kindMiB, "102400", func(o *MountOptions) any { return &o.CacheSize }},
+	{[]string{"free-space-ratio"}, kindFloat, "0.1", func(o *MountOptions) any { return &o.FreeSpaceRatio }},
+	{[]string{"cache-partial-only"}, kindBool, "false", func(o *MountOptions) any { return &o.CachePartialOnly }},
+	{[]string{"buffer-size"}, kindMiB, "300", func(o *MountOptions) any { return &o.BufferSize }},
+	{[]string{"prefetch"}, kindInt, "1", func(o *MountOptions) any { return &o.Prefetch }},
+	{[]string{"writeback"}, kindBool, "false", func(o *MountOptions) any { return &o.Writeback }},
+	{[]string{"flush-wait"}, kindSeconds, "5", func(o *MountOptions) any { return &o.FlushWait }},
+	{[]string{"upload-limit"}, kindInt, "0", func(o *MountOptions) any { return &o.UploadLimit }},
+	{[]string{"download-limit"}, kindInt, "0", func(o *MountOptions) any { return &o.DownloadLimit }},
+	{[]string{"max-uploads"}, kindInt, "20", func(o *MountOptions) any { return &o.MaxUploads }},
+	{[]string{"attr-cache", "attrcacheto"}, kindSeconds, "1", func(o *MountOptions) any { return &o.AttrCache }},
+	{[]string{"entry-cache", "entrycacheto"}, kindSeconds, "1", func(o *MountOptions) any { return &o.EntryCache }},
+	{[]string{"dir-entry-cache", "direntrycacheto"}, kindSeconds, "1", func(o *MountOptions) any { return &o.DirEntryCache }},
+	{[]string{"open-cache", "opencache"}, kindSeconds, "0", func(o *MountOptions) any { return &o.OpenCache }},
+	{[]string{"read-only", "ro"}, kindBool, "false", func(o *MountOptions) any { return &o.ReadOnly }},
+	{[]string{"subdir"}, kindString, "", func(o *MountOptions) any { return &o.SubDir }},
+}
+
+// otherMountFlags are the flags of `juicefs mount` not parsed into MountOptions, mapped to whether
+// they take a value. Bool flags must not take the next argument, which is the meta URL or the
+// mountpoint, e.g. `juicefs mount -d META-URL MOUNTPOINT`. A flag not listed takes a value only
+// if it's written as --flag=value.
+var otherMountFlags = map[string]bool{
+	// bool flags
+	"d":                  false,
+	"background":         false,
+	"foreground":         false,
+	"f":                  false,
+	"no-syslog":          false,
+	"no-usage-report":    false,
+	"no-bgjob":           false,
+	"no-color":           false,
+	"enable-xattr":       false,
+	"enable-ioctl":       false,
+	"enable-acl":         false,
+	"no-bsd-lock":        false,
+	"no-posix-lock":      false,
+	"update-fstab":       false,
+	"backup-skip-trash":  false,
+	"verbose":            false,
+	"debug":              false,
+	"v":                  false,
+	"quiet":              false,
+	"q":                  false,
+	"trace":              false,
+	"allow-other":        false,
+	"disable-cache-lock": false,
+	// flags with a value
+	"log":                   true,
+	"metrics":               true,
+	"consul":                true,
+	"custom-labels":         true,
+	"heartbeat":             true,
+	"bucket":                true,
+	"storage":               true,
+	"storage-class":         true,
+	"get-timeout":           true,
+	"put-timeout":           true,
+	"io-retries":            true,
+	"max-deletions":         true,
+	"skip-dir-nlink":        true,
+	"skip-dir-mtime":        true,
+	"atime-mode":            true,
+	"backup-meta":           true,
+	"upload-delay":          true,
+	"upload-hours":          true,
+	"cache-mode":            true,
+	"cache-items":           true,
+	"cache-eviction":        true,
+	"cache-scan-interval":   true,
+	"cache-expire":          true,
+	"verify-cache-checksum": true,
+	"access-log":            true,
+	"max-readahead":         true,
+	"fuse-fd":               true,
+	"cache-group":           true,
+	"group-weight":          true,
+	"rsa-key":               true,
+}
+
+func findMountFlag(name string) (mountFlag, bool) {
+	for _, f := range mountFlags {
+		for _, n := range f.names {
+			if n == name {
+				return f, true
+			}
+		}
+	}
+	return mountFlag{}, false
+}
+
+// DefaultMountOptions returns the options of a client mounted without flags.
+func DefaultMountOptions() *MountOptions {
+	o := &MountOptions{FuseOptions: []string{}, Other: map[string]string{}, Changed: []ChangedOption{}}
+	for _, f := range mountFlags {
+		if err := f.set(o, f.def); err != nil {
+			panic(fmt.Sprintf("invalid default of %s: %v", f.names[0], err))
+		}
+	}
+	return o
+}
+
+func (f mountFlag) set(o *MountOptions, value string) error {
+	switch p := f.field(o).(type) {
+	case *string:
+		*p = value
+	case *bool:
+		v, err := strconv.ParseBool(value)
+		if err != nil {
+			return err
+		}
+		*p = v
+	case *int64:
+		var err error
+		if f.kind == kindMiB {
+			*p, err = parseMiB(value)
+		} else {
+			*p, err = strconv.ParseInt(value, 10, 64)
+		}
+		return err
+	case *float64:
+		var err error
+		if f.kind == kindSeconds {
+			*p, err = parseSeconds(value)
+		} else {
+			*p, err = strconv.ParseFloat(value, 64)
+		}
+		return err
+	}
+	return nil
+}
+
+// parseSeconds parses a number of seconds or a duration like 1.5s.
+func parseSeconds(value string) (float64, error) {
+	if v, err := strconv.ParseFloat(value, 64); err == nil {
+		return v, nil
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, err
+	}
+	return d.Seconds(), nil
+}
+
+// parseMiB parses a number of MiB or a size with a unit like 512K, 100G or 1TiB.
+func parseMiB(value string) (int64, error) {
+	s := strings.TrimSuffix(strings.TrimSuffix(strings.ToUpper(value), "B"), "I")
+	if v, err := strconv.ParseFloat(s, 64); err == nil {
+		return int64(v), nil
+	}
+	if s == "" {
+		return 0, fmt.Errorf("invalid size %q", value)
+	}
+	shift, ok := map[byte]float64{'K': -10, 'M': 0, 'G': 10, 'T': 20, 'P': 30}[s[len(s)-1]]
+	if !ok {
+		return 0, fmt.Errorf("invalid size %q", value)
+	}
+	v, err := strconv.ParseFloat(s[:len(s)-1], 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid size %q", value)
+	}
+	return int64(v * math.Pow(2, shift)), nil
+}
+
+// ParseMountArgs parses the command line of a client, either `juicefs mount [flags] META-URL MOUNTPOINT`
+// or `mount.juicefs META-URL MOUNTPOINT -o flag=value,...` as mounted by fstab and the CSI driver.
+func ParseMountArgs(args []string) (*MountOptions, error) {
+	o, _, err := parseMountArgs(args)
+	return o, err
+}
+
+// parseMountArgs parses the command line of a client, and also returns the meta URL in clear text.
+func parseMountArgs(args []string) (*MountOptions, string, error) {
+	o := DefaultMountOptions()
+	explicit := map[string]bool{}
+	apply := func(name, value string, hasValue bool) error {
+		f, ok := findMountFlag(name)
+		if !ok {
+			if !hasValue {
+				value = "true"
+			} else if utils.IsSensitiveKey(name) {
+				value = utils.Redacted
+			}
+			o.Other[name] = value
+			return nil
+		}
+		if !hasValue && f.kind == kindBool {
+			value = "true"
+		}
+		if err := f.set(o, value); err != nil {
+			return fmt.Errorf("invalid --%s %q: %w", name, value, err)
+		}
+		explicit[f.names[0]] = true
+		return nil
+	}
+
+	positional := []string{}
+	for i := 1; i < len(args); i++ {
+		arg := args[i]
+		if arg == "mount" && len(positional) == 0 && filepath.Base(args[0]) != "mount.juicefs" {
+			continue
+		}
+		if !strings.HasPrefix(arg, "-") || arg == "-" {
+			positional = append(positional, arg)
+			continue
+		}
+		name, value, hasValue := strings.Cut(strings.TrimLeft(arg, "-"), "=")
+		if name == "o" {
+			if !hasValue && i+1 < len(args) {
+				i++
+				value = args[i]
+			}
+			for _, opt := range strings.Split(value, ",") {
+				key, v, ok := strings.Cut(opt, "=")
+				if _, known := findMountFlag(key); known {
+					if err := apply(key, v, ok); err != nil {
+						return nil, "", err
+					}
+				} else if ok && utils.IsSensitiveKey(key) {
+					o.FuseOptions = append(o.FuseOptions, key+"="+utils.Redacted)
+				} else if opt != "" {
+					o.FuseOptions = append(o.FuseOptions, opt)
+				}
+			}
+			continue
+		}
+		f, known := findMountFlag(name)
+		takesValue := known && f.kind != kindBool || !known && otherMountFlags[name]
+		if !hasValue && takesValue && i+1 < len(args) && !strings.HasPrefix(args[i+1], "-") {
+			i++
+			value, hasValue = args[i], true
+		}
+		if err := apply(name, value, hasValue); err != nil {
+			return nil, "", err
+		}
+	}
+	metaURL := ""
+	if len(positional) > 0 {
+		metaURL = positional[0]
+		o.MetaURL = utils.RedactURL(metaURL)
+	}
+	if len(positional) > 1 {
+		o.Mountpoint = positional[1]
+	}
+
+	defaults := DefaultMountOptions()
+	for _, f := range mountFlags {
+		value := reflect.ValueOf(f.field(o)).Elem().Interface()
+		def := reflect.ValueOf(f.field(defaults)).Elem().Interface()
+		if explicit[f.names[0]] && !reflect.DeepEqual(value, def) {
+			o.Changed = append(o.Changed, ChangedOption{Name: f.names[0], Value: value, Default: def})
+		}
+	}
+	return o, metaURL, nil
+}
+
+// mountOptions returns the options of the client serving mountpoint, read from its command line.
+func (j *JuiceFSHandler) mountOptions(ctx context.Context, mountpoint string) (*MountOptions, error) {
+	opts, _, err := j.clientArgs(ctx, mountpoint)
+	return opts, err
+}
+
+// clientArgs parses the command line of the client serving mountpoint, the meta URL is also
+// returned in clear text and must not leave the server.
+func (j *JuiceFSHandler) clientArgs(ctx context.Context, mountpoint string) (*MountOptions, string, error) {
+	mount, err := j.mounts.Find(ctx, mountpoint)
+	if err != nil {
+		return nil, "", err
+	}
+	if mount.PID == 0 {
+		return nil, "", fmt.Errorf("client process of %s not found", mountpoint)
+	}
+	cmdline, err := os.ReadFile(filepath.Join(j.mounts.ProcRoot, strconv.Itoa(mount.PID), "cmdline"))
+	if err != nil {
+		return nil, "", fmt.Errorf("read cmdline of client %d error: %w", mount.PID, err)
+	}
+	opts, metaURL, err := parseMountArgs(splitClientCmdline(cmdline))
+	if err != nil {
+		return nil, "", err
+	}
+	opts.PID = mount.PID
+	return opts, metaURL, nil
+}
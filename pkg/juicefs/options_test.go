@@ -0,0 +1,156 @@
+package juicefs
+
+import (
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseMountArgs(t *testing.T) {
+	tests := []struct {
+		name       string
+		args       string
+		metaURL    string
+		mountpoint string
+		check      func(t *testing.T, o *MountOptions)
+	}{
+		{
+			name:       "background flag before the meta URL",
+			args:       "juicefs mount -d redis://:pw@host/1 /jfs",
+			metaURL:    "redis://:******@host/1",
+			mountpoint: "/jfs",
+			check: func(t *testing.T, o *MountOptions) {
+				if o.Other["d"] != "true" {
+					t.Errorf("Other[d] = %q, want true", o.Other["d"])
+				}
+			},
+		},
+		{
+			name:       "bool flags not parsed into options",
+			args:       "juicefs mount --no-usage-report --enable-xattr --background redis://:pw@host/1 /jfs",
+			metaURL:    "redis://:******@host/1",
+			mountpoint: "/jfs",
+			check: func(t *testing.T, o *MountOptions) {
+				for _, name := range []string{"no-usage-report", "enable-xattr", "background"} {
+					if o.Other[name] != "true" {
+						t.Errorf("Other[%s] = %q, want true", name, o.Other[name])
+					}
+				}
+			},
+		},
+		{
+			name:       "unknown flag takes a value only with =",
+			args:       "juicefs mount --some-new-flag --other-new=1 redis://host/1 /jfs",
+			metaURL:    "redis://host/1",
+			mountpoint: "/jfs",
+			check: func(t *testing.T, o *MountOptions) {
+				want := map[string]string{"some-new-flag": "true", "other-new": "1"}
+				if !reflect.DeepEqual(o.Other, want) {
+					t.Errorf("Other = %v, want %v", o.Other, want)
+				}
+			},
+		},
+		{
+			name:       "flags with values separated by spaces",
+			args:       "juicefs mount --cache-size 200G --log /var/log/jfs.log --metrics 0.0.0.0:9567 --writeback -d redis://host/1 /jfs",
+			metaURL:    "redis://host/1",
+			mountpoint: "/jfs",
+			check: func(t *testing.T, o *MountOptions) {
+				if o.CacheSize != 200<<10 {
+					t.Errorf("CacheSize = %d, want %d", o.CacheSize, 200<<10)
+				}
+				if !o.Writeback {
+					t.Error("Writeback not set")
+				}
+				if o.Other["log"] != "/var/log/jfs.log" || o.Other["metrics"] != "0.0.0.0:9567" {
+					t.Errorf("Other = %v", o.Other)
+				}
+			},
+		},
+		{
+			name:       "mount.juicefs with -o options",
+			args:       "/sbin/mount.juicefs redis://:pw@host/1 /jfs -o cache-size=1024,writeback,allow_other,secret-key=sk",
+			metaURL:    "redis://:******@host/1",
+			mountpoint: "/jfs",
+			check: func(t *testing.T, o *MountOptions) {
+				if o.CacheSize != 1024 || !o.Writeback {
+					t.Errorf("CacheSize = %d, Writeback = %v", o.CacheSize, o.Writeback)
+				}
+				want := []string{"allow_other", "secret-key=******"}
+				if !reflect.DeepEqual(o.FuseOptions, want) {
+					t.Errorf("FuseOptions = %v, want %v", o.FuseOptions, want)
+				}
+			},
+		},
+		{
+			name:       "enterprise flag names",
+			args:       "juicefs mount --attrcacheto=2 --opencache 1.5s myjfs /jfs",
+			metaURL:    "myjfs",
+			mountpoint: "/jfs",
+			check: func(t *testing.T, o *MountOptions) {
+				if o.AttrCache != 2 || o.OpenCache != 1.5 {
+					t.Errorf("AttrCache = %v, OpenCache = %v", o.AttrCache, o.OpenCache)
+				}
+				if len(o.Changed) != 2 {
+					t.Errorf("Changed = %v, want attr-cache and open-cache", o.Changed)
+				}
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			o, err := ParseMountArgs(strings.Fields(tt.args))
+			if err != nil {
+				t.Fatalf("ParseMountArgs error: %v", err)
+			}
+			if o.MetaURL != tt.metaURL {
+				t.Errorf("MetaURL = %q, want %q", o.MetaURL, tt.metaURL)
+			}
+			if o.Mountpoint != tt.mountpoint {
+				t.Errorf("Mountpoint = %q, want %q", o.Mountpoint, tt.mountpoint)
+			}
+			for name, value := range o.Other {
+				if strings.Contains(value, "pw") {
+					t.Errorf("Other[%s] = %q leaks the password", name, value)
+				}
+			}
+			if tt.check != nil {
+				tt.check(t, o)
+			}
+		})
+	}
+}
+
+func TestParseMountArgsInvalid(t *testing.T) {
+	if _, err := ParseMountArgs(strings.Fields("juicefs mount --cache-size lots redis://host/1 /jfs")); err == nil {
+		t.Error("invalid --cache-size accepted")
+	}
+}
+
+func TestParseRewrittenProcTitle(t *testing.T) {
+	// a client hiding its meta password rewrites its process title into one string
+	cmdline, err := os.ReadFile("testdata/cmdline_title")
+	if err != nil {
+		t.Fatal(err)
+	}
+	args := splitClientCmdline(cmdline)
+	if len(args) != 8 || args[len(args)-1] != "/jfs" {
+		t.Fatalf("args = %q", args)
+	}
+	o, metaURL, err := parseMountArgs(args)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if metaURL != "redis://:****@127.0.0.1:6379/1" || o.Mountpoint != "/jfs" {
+		t.Errorf("meta URL %q, mountpoint %q", metaURL, o.Mountpoint)
+	}
+	if o.CacheSize != 1024 || o.BufferSize != 600 {
+		t.Errorf("CacheSize = %d, BufferSize = %d, want 1024 and 600", o.CacheSize, o.BufferSize)
+	}
+
+	// NUL separated args are kept even if one has spaces
+	if args := splitClientCmdline([]byte("juicefs\x00mount\x00redis://h/1\x00/mnt/my data\x00")); len(args) != 4 || args[3] != "/mnt/my data" {
+		t.Errorf("args = %q", args)
+	}
+}
@@ -0,0 +1,232 @@
+package juicefs
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+)
+
+// StatsSample is one sample of the metrics of a mount, like a line of `juicefs stats`. Rates are per second.
+type StatsSample struct {
+	Usage      UsageStats      `json:"usage"`
+	Fuse       FuseStats       `json:"fuse"`
+	Meta       MetaStats       `json:"meta"`
+	BlockCache BlockCacheStats `json:"blockcache"`
+	Object     ObjectStats     `json:"object"`
+}
+
+type UsageStats struct {
+	CPUPercent float64 `json:"cpuPercent"`
+	MemBytes   float64 `json:"memBytes"`
+	BufBytes   float64 `json:"bufBytes"`
+	CacheBytes float64 `json:"cacheBytes"`
+}
+
+type FuseStats struct {
+	OpsPerSec        float64 `json:"opsPerSec"`
+	LatencyMs        float64 `json:"latencyMs"`
+	ReadBytesPerSec  float64 `json:"readBytesPerSec"`
+	WriteBytesPerSec float64 `json:"writeBytesPerSec"`
+}
+
+type MetaStats struct {
+	OpsPerSec    float64 `json:"opsPerSec"`
+	LatencyMs    float64 `json:"latencyMs"`
+	TxnPerSec    float64 `json:"txnPerSec"`
+	TxnLatencyMs float64 `json:"txnLatencyMs"`
+	RetryPerSec  float64 `json:"retryPerSec"`
+}
+
+// BlockCacheStats are the bytes read from (hits) and written to the local block cache,
+// misses are read from the object storage and show up as object gets.
+type BlockCacheStats struct {
+	HitBytesPerSec   float64 `json:"hitBytesPerSec"`
+	WriteBytesPerSec float64 `json:"writeBytesPerSec"`
+}
+
+type ObjectStats struct {
+	GetBytesPerSec float64 `json:"getBytesPerSec"`
+	GetOpsPerSec   float64 `json:"getOpsPerSec"`
+	GetLatencyMs   float64 `json:"getLatencyMs"`
+	PutBytesPerSec float64 `json:"putBytesPerSec"`
+	PutOpsPerSec   float64 `json:"putOpsPerSec"`
+	PutLatencyMs   float64 `json:"putLatencyMs"`
+	DelOpsPerSec   float64 `json:"delOpsPerSec"`
+	DelLatencyMs   float64 `json:"delLatencyMs"`
+}
+
+// ColumnSummary is the min/avg/max of one column over all samples.
+type ColumnSummary struct {
+	Column string  `json:"column"`
+	Unit   string  `json:"unit"`
+	Min    float64 `json:"min"`
+	Avg    float64 `json:"avg"`
+	Max    float64 `json:"max"`
+}
+
+type StatsResult struct {
+	Mountpoint string          `json:"mountpoint"`
+	Interval   int             `json:"interval"`
+	Samples    []StatsSample   `json:"samples"`
+	Summary    []ColumnSummary `json:"summary"`
+	// Histograms are the latencies over the whole window
+	Histograms []HistogramStats `json:"histograms"`
+}
+
+// statsColumn is a column of the summary, named <section>.<column> like `juicefs stats`.
+type statsColumn struct {
+	name  string
+	unit  string
+	field func(s *StatsSample) *float64
+}
+
+var statsColumns = []statsColumn{
+	{"usage.cpu", "%", func(s *StatsSample) *float64 { return &s.Usage.CPUPercent }},
+	{"usage.mem", "B", func(s *StatsSample) *float64 { return &s.Usage.MemBytes }},
+	{"usage.buf", "B", func(s *StatsSample) *float64 { return &s.Usage.BufBytes }},
+	{"usage.cache", "B", func(s *StatsSample) *float64 { return &s.Usage.CacheBytes }},
+	{"fuse.ops", "ops/s", func(s *StatsSample) *float64 { return &s.Fuse.OpsPerSec }},
+	{"fuse.lat", "ms", func(s *StatsSample) *float64 { return &s.Fuse.LatencyMs }},
+	{"fuse.read", "B/s", func(s *StatsSample) *float64 { return &s.Fuse.ReadBytesPerSec }},
+	{"fuse.write", "B/s", func(s *StatsSample) *float64 { return &s.Fuse.WriteBytesPerSec }},
+	{"meta.ops", "ops/s", func(s *StatsSample) *float64 { return &s.Meta.OpsPerSec }},
+	{"meta.lat", "ms", func(s *StatsSample) *float64 { return &s.Meta.LatencyMs }},
+	{"meta.txn", "ops/s", func(s *StatsSample) *float64 { return &s.Meta.TxnPerSec }},
+	{"meta.txn_lat", "ms", func(s *StatsSample) *float64 { return &s.Meta.TxnLatencyMs }},
+	{"meta.retry", "ops/s", func(s *StatsSample) *float64 { return &s.Meta.RetryPerSec }},
+	{"blockcache.read", "B/s", func(s *StatsSample) *float64 { return &s.BlockCache.HitBytesPerSec }},
+	{"blockcache.write", "B/s", func(s *StatsSample) *float64 { return &s.BlockCache.WriteBytesPerSec }},
+	{"object.get", "B/s", func(s *StatsSample) *float64 { return &s.Object.GetBytesPerSec }},
+	{"object.get_c", "ops/s", func(s *StatsSample) *float64 { return &s.Object.GetOpsPerSec }},
+	{"object.get_lat", "ms", func(s *StatsSample) *float64 { return &s.Object.GetLatencyMs }},
+	{"object.put", "B/s", func(s *StatsSample) *float64 { return &s.Object.PutBytesPerSec }},
+	{"object.put_c", "ops/s", func(s *StatsSample) *float64 { return &s.Object.PutOpsPerSec }},
+	{"object.put_lat", "ms", func(s *StatsSample) *float64 { return &s.Object.PutLatencyMs }},
+	{"object.del_c", "ops/s", func(s *StatsSample) *float64 { return &s.Object.DelOpsPerSec }},
+	{"object.del_lat", "ms", func(s *StatsSample) *float64 { return &s.Object.DelLatencyMs }},
+}
+
+// metric names of the JuiceFS client
+const (
+	metricCPUUsage        = "juicefs_cpu_usage"
+	metricMemory          = "juicefs_memory"
+	metricBufferSize      = "juicefs_used_buffer_size_bytes"
+	metricCacheSize       = "juicefs_blockcache_bytes"
+	metricFuseOps         = "juicefs_fuse_ops_durations_histogram_seconds"
+	metricFuseRead        = "juicefs_fuse_read_size_bytes"
+	metricFuseWritten     = "juicefs_fuse_written_size_bytes"
+	metricMetaOps         = "juicefs_meta_ops_durations_histogram_seconds"
+	metricTxn             = "juicefs_transaction_durations_histogram_seconds"
+	metricTxnRestart      = "juicefs_transaction_restart"
+	metricBlockCacheHit   = "juicefs_blockcache_hit_bytes"
+	metricBlockCacheWrite = "juicefs_blockcache_write_bytes"
+	metricObjectBytes     = "juicefs_object_request_data_bytes"
+	metricObjectDurations = "juicefs_object_request_durations_histogram_seconds"
+	metricCompactSize     = "juicefs_compact_size_histogram_bytes"
+)
+
+// statsSample computes the rates between two snapshots, gauges are taken from the later one.
+func statsSample(from, to *Snapshot) StatsSample {
+	dt := to.Time.Sub(from.Time).Seconds()
+	rate := func(base, suffix, method string) float64 {
+		if dt <= 0 {
+			return 0
+		}
+		return (to.Value(base, suffix, method) - from.Value(base, suffix, method)) / dt
+	}
+	hist := func(base, method string) (float64, float64) {
+		h := histogram(base, base, method, from, to)
+		return h.RatePerSec, h.AvgMs
+	}
+	sample := StatsSample{
+		Usage: UsageStats{
+			CPUPercent: rate(metricCPUUsage, "", "") * 100,
+			MemBytes:   to.Value(metricMemory, "", ""),
+			BufBytes:   to.Value(metricBufferSize, "", ""),
+			CacheBytes: to.Value(metricCacheSize, "", ""),
+		},
+		Fuse: FuseStats{
+			ReadBytesPerSec:  rate(metricFuseRead, "_sum", ""),
+			WriteBytesPerSec: rate(metricFuseWritten, "_sum", ""),
+		},
+		Meta: MetaStats{RetryPerSec: rate(metricTxnRestart, "", "")},
+		BlockCache: BlockCacheStats{
+			HitBytesPerSec:   rate(metricBlockCacheHit, "", ""),
+			WriteBytesPerSec: rate(metricBlockCacheWrite, "", ""),
+		},
+		Object: ObjectStats{
+			GetBytesPerSec: rate(metricObjectBytes, "", "GET"),
+			PutBytesPerSec: rate(metricObjectBytes, "", "PUT"),
+		},
+	}
+	sample.Fuse.OpsPerSec, sample.Fuse.LatencyMs = hist(metricFuseOps, "")
+	sample.Meta.OpsPerSec, sample.Meta.LatencyMs = hist(metricMetaOps, "")
+	sample.Meta.TxnPerSec, sample.Meta.TxnLatencyMs = hist(metricTxn, "")
+	sample.Object.GetOpsPerSec, sample.Object.GetLatencyMs = hist(metricObjectDurations, "GET")
+	sample.Object.PutOpsPerSec, sample.Object.PutLatencyMs = hist(metricObjectDurations, "PUT")
+	sample.Object.DelOpsPerSec, sample.Object.DelLatencyMs = hist(metricObjectDurations, "DELETE")
+	return sample
+}
+
+// statsHistograms computes the latency histograms over the whole window.
+func statsHistograms(from, to *Snapshot) []HistogramStats {
+	return []HistogramStats{
+		histogram("fuse", metricFuseOps, "", from, to),
+		histogram("meta", metricMetaOps, "", from, to),
+		histogram("meta.txn", metricTxn, "", from, to),
+		histogram("object.get", metricObjectDurations, "GET", from, to),
+		histogram("object.put", metricObjectDurations, "PUT", from, to),
+		histogram("object.delete", metricObjectDurations, "DELETE", from, to),
+	}
+}
+
+// collectStats computes a sample between every two adjacent snapshots.
+func collectStats(mountpoint string, snapshots []*Snapshot) StatsResult {
+	result := StatsResult{Mountpoint: mountpoint, Samples: []StatsSample{}, Summary: []ColumnSummary{}}
+	if len(snapshots) < 2 {
+		return result
+	}
+	for i := 1; i < len(snapshots); i++ {
+		result.Samples = append(result.Samples, statsSample(snapshots[i-1], snapshots[i]))
+	}
+	for _, column := range statsColumns {
+		s := ColumnSummary{Column: column.name, Unit: column.unit, Min: math.Inf(1), Max: math.Inf(-1)}
+		for i := range result.Samples {
+			v := *column.field(&result.Samples[i])
+			s.Min = math.Min(s.Min, v)
+			s.Max = math.Max(s.Max, v)
+			s.Avg += v
+		}
+		s.Avg /= float64(len(result.Samples))
+		result.Summary = append(result.Summary, s)
+	}
+	result.Histograms = statsHistograms(snapshots[0], snapshots[len(snapshots)-1])
+	return result
+}
+
+// formatSummary renders the summary as a human readable table.
+func formatSummary(summary []ColumnSummary) string {
+	buf := &strings.Builder{}
+	w := tabwriter.NewWriter(buf, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(w, "column\tunit\tmin\tavg\tmax\t")
+	for _, s := range summary {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t\n", s.Column, s.Unit, humanize(s.Min, s.Unit), humanize(s.Avg, s.Unit), humanize(s.Max, s.Unit))
+	}
+	_ = w.Flush()
+	return buf.String()
+}
+
+func humanize(v float64, unit string) string {
+	if unit != "B" && unit != "B/s" {
+		return strconv.FormatFloat(v, 'f', 2, 64)
+	}
+	units := []string{"", "K", "M", "G", "T", "P"}
+	i := 0
+	for v >= 1024 && i < len(units)-1 {
+		v /= 1024
+		i++
+	}
+	return strconv.FormatFloat(v, 'f', 1, 64) + units[i]
+}
@@ -0,0 +1,181 @@
+package juicefs
+
+import (
+	"context"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func readSnapshot(t *testing.T, file string, at time.Time) *Snapshot {
+	f, err := os.Open(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	s, err := ParseSnapshot(f, at)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func TestCollectStatsFromStatsFile(t *testing.T) {
+	start := time.Unix(1700000000, 0)
+	// two reads of the .stats file of a client 10s apart, histograms flattened into names
+	from := readSnapshot(t, "testdata/stats.0", start)
+	to := readSnapshot(t, "testdata/stats.1", start.Add(10*time.Second))
+	result := collectStats("/jfs", []*Snapshot{from, to})
+	if len(result.Samples) != 1 {
+		t.Fatalf("got %d samples, want 1", len(result.Samples))
+	}
+
+	want := map[string]float64{
+		"usage.cpu":        10,
+		"usage.mem":        200 << 20,
+		"usage.buf":        64 << 20,
+		"usage.cache":      1120 << 20,
+		"fuse.ops":         500,
+		"fuse.lat":         2,
+		"fuse.read":        12 << 20,
+		"fuse.write":       10 << 20,
+		"meta.ops":         100,
+		"meta.lat":         2,
+		"meta.txn":         50,
+		"meta.txn_lat":     2,
+		"meta.retry":       2,
+		"blockcache.read":  2 << 20,
+		"blockcache.write": 10 << 20,
+		"object.get":       10 << 20,
+		"object.get_c":     2.5,
+		"object.get_lat":   100,
+		"object.put":       10 << 20,
+		"object.put_c":     2.5,
+		"object.put_lat":   200,
+		"object.del_c":     1,
+		"object.del_lat":   10,
+	}
+	if len(result.Summary) != len(want) {
+		t.Errorf("got %d summary columns, want %d", len(result.Summary), len(want))
+	}
+	for _, s := range result.Summary {
+		w, ok := want[s.Column]
+		if !ok {
+			t.Errorf("unexpected column %s", s.Column)
+			continue
+		}
+		if math.Abs(s.Avg-w) > 1e-6*math.Max(1, w) || s.Min != s.Avg || s.Max != s.Avg {
+			t.Errorf("%s = min %g avg %g max %g, want %g", s.Column, s.Min, s.Avg, s.Max, w)
+		}
+	}
+	for _, h := range result.Histograms {
+		// the flattened histograms have no buckets
+		if h.P50Ms != nil || len(h.Buckets) != 0 {
+			t.Errorf("histogram %s has percentiles without buckets: %+v", h.Name, h)
+		}
+	}
+}
+
+func TestCollectStatsNeedsTwoSnapshots(t *testing.T) {
+	from := readSnapshot(t, "testdata/stats.0", time.Now())
+	result := collectStats("/jfs", []*Snapshot{from})
+	if len(result.Samples) != 0 || len(result.Summary) != 0 {
+		t.Errorf("got samples from one snapshot: %+v", result)
+	}
+}
+
+func TestHistogramFromBuckets(t *testing.T) {
+	start := time.Unix(1700000000, 0)
+	parse := func(text string, at time.Time) *Snapshot {
+		s, err := ParseSnapshot(strings.NewReader(text), at)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return s
+	}
+	from := parse(`
+# HELP juicefs_object_request_durations_histogram_seconds Object requests latency distributions.
+# TYPE juicefs_object_request_durations_histogram_seconds histogram
+juicefs_object_request_durations_histogram_seconds_bucket{method="GET",le="0.01"} 0
+juicefs_object_request_durations_histogram_seconds_bucket{method="GET",le="0.1"} 0
+juicefs_object_request_durations_histogram_seconds_bucket{method="GET",le="+Inf"} 0
+juicefs_object_request_durations_histogram_seconds_sum{method="GET"} 0
+juicefs_object_request_durations_histogram_seconds_count{method="GET"} 0
+juicefs_object_request_durations_histogram_seconds_count{method="PUT"} 7
+`, start)
+	to := parse(`
+juicefs_object_request_durations_histogram_seconds_bucket{method="GET",le="0.01"} 50
+juicefs_object_request_durations_histogram_seconds_bucket{method="GET",le="0.1"} 100
+juicefs_object_request_durations_histogram_seconds_bucket{method="GET",le="+Inf"} 100
+juicefs_object_request_durations_histogram_seconds_sum{method="GET"} 2 1700000010000
+juicefs_object_request_durations_histogram_seconds_count{method="GET"} 100
+juicefs_object_request_durations_histogram_seconds_count{method="PUT"} 9
+`, start.Add(10*time.Second))
+
+	h := histogram("object.get", metricObjectDurations, "GET", from, to)
+	if h.Count != 100 || h.RatePerSec != 10 || h.AvgMs != 20 {
+		t.Errorf("count %g rate %g avg %g, want 100, 10, 20", h.Count, h.RatePerSec, h.AvgMs)
+	}
+	if h.P50Ms == nil || math.Abs(*h.P50Ms-10) > 1e-9 {
+		t.Errorf("p50 = %v, want 10ms", h.P50Ms)
+	}
+	if h.P99Ms == nil || math.Abs(*h.P99Ms-98.2) > 1e-9 {
+		t.Errorf("p99 = %v, want 98.2ms", h.P99Ms)
+	}
+	if len(h.Buckets) != 2 {
+		t.Errorf("got buckets %+v, want the 2 finite ones", h.Buckets)
+	}
+	if put := histogram("object.put", metricObjectDurations, "PUT", from, to); put.Count != 2 {
+		t.Errorf("PUT count = %g, want 2", put.Count)
+	}
+}
+
+func TestParseSnapshotInvalid(t *testing.T) {
+	for _, line := range []string{
+		"juicefs_memory",
+		"juicefs_memory abc",
+		`juicefs_object_request_data_bytes{method="GET" 1`,
+		`juicefs_object_request_data_bytes{method} 1`,
+	} {
+		if _, err := ParseSnapshot(strings.NewReader(line), time.Now()); err == nil {
+			t.Errorf("ParseSnapshot(%q) succeeded", line)
+		}
+	}
+}
+
+func TestReadSnapshotTimeout(t *testing.T) {
+	data, err := os.ReadFile("testdata/stats.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	mountpoint := t.TempDir()
+	if err := os.WriteFile(filepath.Join(mountpoint, StatsFile), data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if s, err := ReadSnapshot(context.Background(), mountpoint, time.Second); err != nil || len(s.series) == 0 {
+		t.Errorf("ReadSnapshot = %v, %v", s, err)
+	}
+
+	// opening a fifo without a writer blocks like a dead mount
+	dead := t.TempDir()
+	if err := syscall.Mkfifo(filepath.Join(dead, StatsFile), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	// a writer releases the blocked open once the test is done
+	t.Cleanup(func() {
+		if f, err := os.OpenFile(filepath.Join(dead, StatsFile), os.O_WRONLY|syscall.O_NONBLOCK, 0); err == nil {
+			f.Close()
+		}
+	})
+	start := time.Now()
+	if _, err := ReadSnapshot(context.Background(), dead, 10*time.Millisecond); err == nil || !strings.Contains(err.Error(), "no response") {
+		t.Errorf("err = %v, want no response", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("ReadSnapshot of a dead mount took %s", elapsed)
+	}
+}
@@ -0,0 +1,317 @@
+package juicefs
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"k8s.io/apimachinery/pkg/util/json"
+
+	"juicefs-mcp/pkg/utils"
+)
+
+// sessionTimeout is how long a session lives after its last heartbeat, 5 heartbeats of 12s by default.
+const sessionTimeout = 60 * time.Second
+
+// FormatSettings are the settings of a volume given by `juicefs format`, credentials are left out.
+type FormatSettings struct {
+	Name         string `json:"name"`
+	UUID         string `json:"uuid"`
+	Storage      string `json:"storage"`
+	Bucket       string `json:"bucket"`
+	BlockSizeKiB int    `json:"blockSizeKiB"`
+	Compression  string `json:"compression"`
+	TrashDays    int    `json:"trashDays"`
+	Shards       int    `json:"shards,omitempty"`
+	HashPrefix   bool   `json:"hashPrefix,omitempty"`
+	// Capacity and Inodes are the quota of the volume, 0 means unlimited
+	CapacityBytes uint64 `json:"capacityBytes,omitempty"`
+	Inodes        uint64 `json:"inodes,omitempty"`
+	EncryptAlgo   string `json:"encryptAlgo,omitempty"`
+	MetaVersion   int    `json:"metaVersion,omitempty"`
+}
+
+// SessionInfo is a client connected to the metadata engine.
+type SessionInfo struct {
+	Sid        uint64 `json:"sid"`
+	Host       string `json:"host"`
+	PID        int    `json:"pid"`
+	Mountpoint string `json:"mountpoint"`
+	Version    string `json:"version"`
+	// HeartbeatAgeSec is the time since the last heartbeat of the client
+	HeartbeatAgeSec float64 `json:"heartbeatAgeSec"`
+	// Stale is set if the session misses its heartbeats, the client is likely gone
+	Stale bool `json:"stale"`
+	// Outdated is set if the client is older than the latest version among the clients
+	Outdated bool `json:"outdated"`
+}
+
+type VolumeStatus struct {
+	Mountpoint string         `json:"mountpoint"`
+	MetaURL    string         `json:"metaURL"`
+	Setting    FormatSettings `json:"setting"`
+	Sessions   []SessionInfo  `json:"sessions"`
+	// LatestVersion is the latest version among the clients and the juicefs binary of the server
+	LatestVersion    string `json:"latestVersion"`
+	StaleSessions    int    `json:"staleSessions"`
+	OutdatedSessions int    `json:"outdatedSessions"`
+	// Statistic is the usage of the volume, given by recent versions only
+	Statistic map[string]any `json:"statistic,omitempty"`
+}
+
+// rawStatus is the JSON printed by `juicefs status`. Sessions have Heartbeat before 1.0 and
+// Expire since then.
+type rawStatus struct {
+	Setting struct {
+		Name        string
+		UUID        string
+		Storage     string
+		Bucket      string
+		BlockSize   int
+		Compression string
+		TrashDays   int
+		Shards      int
+		HashPrefix  bool
+		Capacity    uint64
+		Inodes      uint64
+		EncryptAlgo string
+		MetaVersion int
+	}
+	Sessions []struct {
+		Sid        uint64
+		Heartbeat  *time.Time
+		Expire     *time.Time
+		Version    string
+		HostName   string
+		MountPoint string
+		ProcessID  int
+	}
+	Statistic map[string]any
+}
+
+// ParseStatusOutput parses the output of `juicefs status`, sessions are judged at now.
+func ParseStatusOutput(output []byte, now time.Time) (*VolumeStatus, error) {
+	start := bytes.IndexByte(output, '{')
+	if start < 0 {
+		return nil, fmt.Errorf("no status in output")
+	}
+	raw := rawStatus{}
+	if err := json.Unmarshal(output[start:], &raw); err != nil {
+		return nil, fmt.Errorf("parse status error: %w", err)
+	}
+	s := raw.Setting
+	status := &VolumeStatus{
+		Setting: FormatSettings{
+			Name:          s.Name,
+			UUID:          s.UUID,
+			Storage:       s.Storage,
+			Bucket:        utils.RedactURL(s.Bucket),
+			BlockSizeKiB:  s.BlockSize,
+			Compression:   s.Compression,
+			TrashDays:     s.TrashDays,
+			Shards:        s.Shards,
+			HashPrefix:    s.HashPrefix,
+			CapacityBytes: s.Capacity,
+			Inodes:        s.Inodes,
+			EncryptAlgo:   s.EncryptAlgo,
+			MetaVersion:   s.MetaVersion,
+		},
+		Sessions:  []SessionInfo{},
+		Statistic: raw.Statistic,
+	}
+	for _, rs := range raw.Sessions {
+		session := SessionInfo{
+			Sid:        rs.Sid,
+			Host:       rs.HostName,
+			PID:        rs.ProcessID,
+			Mountpoint: rs.MountPoint,
+			Version:    rs.Version,
+		}
+		switch {
+		case rs.Heartbeat != nil:
+			session.HeartbeatAgeSec = now.Sub(*rs.Heartbeat).Seconds()
+			session.Stale = now.Sub(*rs.Heartbeat) > sessionTimeout
+		case rs.Expire != nil:
+			session.HeartbeatAgeSec = now.Sub(rs.Expire.Add(-sessionTimeout)).Seconds()
+			session.Stale = now.After(*rs.Expire)
+		}
+		if session.Stale {
+			status.StaleSessions++
+		}
+		status.Sessions = append(status.Sessions, session)
+	}
+	return status, nil
+}
+
+// markOutdated flags the sessions older than the latest version among them and latest.
+func (s *VolumeStatus) markOutdated(latest *Version) {
+	for _, session := range s.Sessions {
+		if v, ok := parseSemver(session.Version); ok && (latest == nil || v.newerThan(latest)) {
+			latest = v
+		}
+	}
+	if latest == nil {
+		return
+	}
+	s.LatestVersion = latest.Version
+	for i := range s.Sessions {
+		session := &s.Sessions[i]
+		if v, ok := parseSemver(session.Version); ok && latest.newerThan(v) {
+			session.Outdated = true
+			s.OutdatedSessions++
+		}
+	}
+}
+
+// semver matches the version of a session, e.g. 1.1.2+2024-02-04.8dbd89a.
+var semver = regexp.MustCompile(`^v?(\d+)\.(\d+)(?:\.(\d+))?`)
+
+func parseSemver(s string) (*Version, bool) {
+	m := semver.FindStringSubmatch(strings.TrimSpace(s))
+	if m == nil {
+		return nil, false
+	}
+	v := &Version{Edition: EditionCE, Raw: s}
+	v.Major, _ = strconv.Atoi(m[1])
+	v.Minor, _ = strconv.Atoi(m[2])
+	v.Patch, _ = strconv.Atoi(m[3])
+	v.Version = fmt.Sprintf("%d.%d.%d", v.Major, v.Minor, v.Patch)
+	return v, true
+}
+
+func (v *Version) newerThan(o *Version) bool {
+	if v.Major != o.Major {
+		return v.Major > o.Major
+	}
+	if v.Minor != o.Minor {
+		return v.Minor > o.Minor
+	}
+	return v.Patch > o.Patch
+}
+
+// metaSecrets are the strings to hide from anything returned about a meta URL: the URL itself, its
+// password, and the password given by META_PASSWORD.
+func metaSecrets(metaURL, metaPassword string) []string {
+	secrets := []string{metaURL}
+	if u, err := url.Parse(metaURL); err == nil && u.User != nil {
+		if password, ok := u.User.Password(); ok && password != "" {
+			secrets = append(secrets, password)
+		}
+	}
+	if metaPassword != "" {
+		secrets = append(secrets, metaPassword)
+	}
+	return secrets
+}
+
+// maskedPassword replaces the password of the meta URL in the process title of a client hiding it.
+const maskedPassword = "****"
+
+// unmaskMetaURL returns the meta URL to run a command with. The masked password of a client that
+// rewrote its process title is dropped from the URL, it is then taken from META_PASSWORD.
+func unmaskMetaURL(metaURL, metaPassword string) (string, error) {
+	masked := ":" + maskedPassword + "@"
+	if !strings.Contains(metaURL, masked) {
+		return metaURL, nil
+	}
+	if metaPassword == "" {
+		return "", errors.New("password of the meta URL is masked in the client command line and META_PASSWORD is not set in its environment")
+	}
+	return strings.Replace(metaURL, masked, "@", 1), nil
+}
+
+func scrubSecrets(s string, secrets []string) string {
+	for _, secret := range secrets {
+		if secret != "" {
+			s = strings.ReplaceAll(s, secret, utils.Redacted)
+		}
+	}
+	return s
+}
+
+// clientEnv returns the variable name in the environment of a client process, empty if it's not set.
+func (j *JuiceFSHandler) clientEnv(pid int, name string) string {
+	environ, err := os.ReadFile(filepath.Join(j.mounts.ProcRoot, strconv.Itoa(pid), "environ"))
+	if err != nil {
+		return ""
+	}
+	for _, kv := range splitCmdline(environ) {
+		if value, ok := strings.CutPrefix(kv, name+"="); ok {
+			return value
+		}
+	}
+	return ""
+}
+
+// volumeStatus runs `juicefs status` on the volume mounted at mountpoint with the meta URL of its
+// client. The secrets returned must be scrubbed from anything built from the status.
+func (j *JuiceFSHandler) volumeStatus(ctx context.Context, mountpoint string) (*VolumeStatus, []string, error) {
+	opts, metaURL, err := j.clientArgs(ctx, mountpoint)
+	if err != nil {
+		return nil, nil, err
+	}
+	if metaURL == "" {
+		return nil, nil, fmt.Errorf("meta URL of %s not found in the client command line", mountpoint)
+	}
+	// the client may take the password of the meta URL from its environment
+	metaPassword := j.clientEnv(opts.PID, "META_PASSWORD")
+	secrets := metaSecrets(metaURL, metaPassword)
+	metaURL, err = unmaskMetaURL(metaURL, metaPassword)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	timeoutCtx, cancel := context.WithTimeout(ctx, j.cmdTimeout)
+	defer cancel()
+	cmd := j.exec.CommandContext(timeoutCtx, j.binPath, "status", metaURL)
+	if metaPassword != "" {
+		cmd.SetEnv(append(os.Environ(), "META_PASSWORD="+metaPassword))
+	}
+	stderr := &bytes.Buffer{}
+	cmd.SetStderr(stderr)
+	out, err := cmd.Output()
+	if errors.Is(timeoutCtx.Err(), context.DeadlineExceeded) {
+		return nil, nil, fmt.Errorf("not finished in %s", j.cmdTimeout)
+	}
+	if err != nil {
+		msg := scrubSecrets(strings.TrimSpace(stderr.String()), secrets)
+		j.log.Errorw("exec status error", "mountpoint", mountpoint, "err", err, "output", msg)
+		return nil, nil, fmt.Errorf("%s: %s", scrubSecrets(err.Error(), secrets), msg)
+	}
+	status, err := ParseStatusOutput(out, time.Now())
+	if err != nil {
+		return nil, nil, err
+	}
+	status.Mountpoint, status.MetaURL = mountpoint, opts.MetaURL
+	return status, secrets, nil
+}
+
+func (j *JuiceFSHandler) handleStatus(
+	ctx context.Context,
+	request mcp.CallToolRequest,
+	args MountpointArgs,
+) (*mcp.CallToolResult, error) {
+	mountpoint := args.Mountpoint
+	j.log.Debugw("handleStatus", "args", args)
+	status, secrets, err := j.volumeStatus(ctx, mountpoint)
+	if err != nil {
+		return nil, fmt.Errorf("status error: %w", err)
+	}
+	var binary *Version
+	if j.version != nil && j.version.Edition == EditionCE {
+		binary = j.version
+	}
+	status.markOutdated(binary)
+	j.log.Debugw("handleStatus", "sessions", len(status.Sessions), "stale", status.StaleSessions, "outdated", status.OutdatedSessions)
+	res, _ := json.Marshal(status)
+	return mcp.NewToolResultText(scrubSecrets(string(res), secrets)), nil
+}
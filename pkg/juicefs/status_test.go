@@ -0,0 +1,110 @@
+package juicefs
+
+import (
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseStatusOutput(t *testing.T) {
+	out, err := os.ReadFile("testdata/status")
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)
+	status, err := ParseStatusOutput(out, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := status.Setting
+	if s.Name != "myjfs" || s.Storage != "s3" || s.BlockSizeKiB != 1024 || s.Compression != "lz4" || s.TrashDays != 1 {
+		t.Errorf("setting = %+v", s)
+	}
+	if strings.Contains(s.Bucket, "secret") || !strings.Contains(s.Bucket, "mybucket.s3.amazonaws.com") {
+		t.Errorf("bucket not redacted: %s", s.Bucket)
+	}
+	if status.Statistic["UsedInodes"] == nil {
+		t.Errorf("statistic = %v", status.Statistic)
+	}
+	if len(status.Sessions) != 2 {
+		t.Fatalf("got %d sessions, want 2", len(status.Sessions))
+	}
+	// a session expires a session timeout after its last heartbeat
+	fresh, stale := status.Sessions[0], status.Sessions[1]
+	if fresh.Stale || fresh.HeartbeatAgeSec != 10 || fresh.Host != "node-1" || fresh.PID != 100 {
+		t.Errorf("fresh session = %+v", fresh)
+	}
+	if !stale.Stale || stale.HeartbeatAgeSec != 660 {
+		t.Errorf("stale session = %+v", stale)
+	}
+	if status.StaleSessions != 1 {
+		t.Errorf("%d stale sessions, want 1", status.StaleSessions)
+	}
+
+	status.markOutdated(nil)
+	if status.LatestVersion != "1.1.2" || status.OutdatedSessions != 1 || status.StaleSessions != 1 || !status.Sessions[1].Outdated {
+		t.Errorf("status = %+v", status)
+	}
+}
+
+func TestParseStatusOutputHeartbeat(t *testing.T) {
+	// sessions of versions before 1.0 have the last heartbeat
+	out := `{"Setting":{"Name":"old","BlockSize":4096},"Sessions":[{"Sid":3,"Heartbeat":"2024-01-01T09:58:00Z","Version":"0.17.5"}]}`
+	status, err := ParseStatusOutput([]byte(out), time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if session := status.Sessions[0]; !session.Stale || session.HeartbeatAgeSec != 120 {
+		t.Errorf("session = %+v", session)
+	}
+	// a newer binary of the server makes every client outdated
+	status.markOutdated(&Version{Major: 1, Minor: 2, Version: "1.2.0"})
+	if status.LatestVersion != "1.2.0" || status.OutdatedSessions != 1 {
+		t.Errorf("status = %+v", status)
+	}
+	for _, out := range []string{"", "<FATAL>: connection refused", "{"} {
+		if _, err := ParseStatusOutput([]byte(out), time.Now()); err == nil {
+			t.Errorf("ParseStatusOutput(%q) succeeded", out)
+		}
+	}
+}
+
+func TestParseStatusOutputUnknownVersions(t *testing.T) {
+	// stale sessions are counted even if no version is known to compare with
+	out := `{"Setting":{"Name":"myjfs"},"Sessions":[{"Sid":1,"Expire":"2024-01-01T09:00:00Z","Version":"unknown"},{"Sid":2,"Expire":"2024-01-01T10:00:30Z"}]}`
+	status, err := ParseStatusOutput([]byte(out), time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatal(err)
+	}
+	status.markOutdated(nil)
+	if status.StaleSessions != 1 || status.OutdatedSessions != 0 || status.LatestVersion != "" {
+		t.Errorf("status = %+v", status)
+	}
+}
+
+func TestMetaSecrets(t *testing.T) {
+	secrets := metaSecrets("redis://user:p@ss@host:6379/1", "envpass")
+	s := scrubSecrets("dial redis://user:p@ss@host:6379/1 with p@ss and envpass", secrets)
+	if strings.Contains(s, "p@ss") || strings.Contains(s, "envpass") {
+		t.Errorf("secrets left in %q", s)
+	}
+}
+
+func TestUnmaskMetaURL(t *testing.T) {
+	for _, c := range []struct {
+		metaURL, metaPassword, want string
+	}{
+		{"redis://:p@host:6379/1", "", "redis://:p@host:6379/1"},
+		{"redis://:****@host:6379/1", "envpass", "redis://@host:6379/1"},
+		{"mysql://root:****@(127.0.0.1:3306)/juicefs", "envpass", "mysql://root@(127.0.0.1:3306)/juicefs"},
+	} {
+		if got, err := unmaskMetaURL(c.metaURL, c.metaPassword); err != nil || got != c.want {
+			t.Errorf("unmaskMetaURL(%q) = %q, %v, want %q", c.metaURL, got, err, c.want)
+		}
+	}
+	// the masked password can't be run with
+	if _, err := unmaskMetaURL("redis://:****@host:6379/1", ""); err == nil {
+		t.Error("masked password without META_PASSWORD accepted")
+	}
+}
@@ -0,0 +1,237 @@
+package juicefs
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"k8s.io/apimachinery/pkg/util/json"
+)
+
+// maxSummaryNodes bounds the tree returned, whatever the depth and entries asked for.
+const maxSummaryNodes = 2000
+
+// SummaryNode is a file or directory in the output of `juicefs summary`, with the recursive size
+// and counts of a directory.
+type SummaryNode struct {
+	Path  string `json:"path"`
+	Size  int64  `json:"size"`
+	Dirs  int64  `json:"dirs"`
+	Files int64  `json:"files"`
+	// Others is set for the entries beyond the limit of a directory, summed up as "..."
+	Others   bool           `json:"others,omitempty"`
+	Children []*SummaryNode `json:"children,omitempty"`
+}
+
+func (n *SummaryNode) isDir() bool {
+	return strings.HasSuffix(n.Path, "/")
+}
+
+type PathSummary struct {
+	Path       string       `json:"path"`
+	Mountpoint string       `json:"mountpoint"`
+	Depth      int          `json:"depth"`
+	Entries    int          `json:"entries"`
+	Root       *SummaryNode `json:"root"`
+	// TopConsumers are the largest files and directories in the tree, "..." entries left out
+	TopConsumers []SummaryNode `json:"topConsumers"`
+	// Truncated is set if the limits left something out of the tree, Notice says what
+	Truncated bool   `json:"truncated"`
+	Notice    string `json:"notice,omitempty"`
+}
+
+// logLine matches the log lines juicefs may print before the summary.
+var logLine = regexp.MustCompile(`<[A-Z]+>:`)
+
+// ParseSummaryOutput parses the CSV output of `juicefs summary --csv`, e.g.
+//
+//	PATH,SIZE,DIRS,FILES
+//	/jfs/,1073750016,3,4
+//	/jfs/big,1073741824,0,1
+//	/jfs/d/,8192,2,3
+//	/jfs/d/...,4096,1,2
+//
+// Entries are listed depth first, directories end with "/". It returns the root and how many
+// nodes were dropped beyond maxNodes.
+func ParseSummaryOutput(output []byte, maxNodes int) (*SummaryNode, int, error) {
+	lines := []string{}
+	for _, line := range strings.Split(ansiEscape.ReplaceAllString(string(output), ""), "\n") {
+		if line = strings.TrimSpace(line); line != "" && !logLine.MatchString(line) {
+			lines = append(lines, line)
+		}
+	}
+	records, err := csv.NewReader(strings.NewReader(strings.Join(lines, "\n"))).ReadAll()
+	if err != nil {
+		return nil, 0, fmt.Errorf("parse summary error: %w", err)
+	}
+	var root *SummaryNode
+	stack, nodes, dropped := []*SummaryNode{}, 0, 0
+	for _, record := range records {
+		if len(record) != 4 || strings.EqualFold(record[0], "path") {
+			continue
+		}
+		node := &SummaryNode{Path: record[0]}
+		if node.Size, err = parseInfoBytes(record[1]); err != nil {
+			return nil, 0, fmt.Errorf("parse summary row %v error: %w", record, err)
+		}
+		node.Dirs, _ = strconv.ParseInt(record[2], 10, 64)
+		node.Files, _ = strconv.ParseInt(record[3], 10, 64)
+		node.Others = strings.HasSuffix(node.Path, "/...") || node.Path == "..."
+		if root == nil {
+			root, stack, nodes = node, []*SummaryNode{node}, 1
+			continue
+		}
+		// pop until the top is the directory holding the node
+		for len(stack) > 1 && !(stack[len(stack)-1].isDir() && strings.HasPrefix(node.Path, stack[len(stack)-1].Path)) {
+			stack = stack[:len(stack)-1]
+		}
+		if nodes >= maxNodes {
+			dropped++
+			continue
+		}
+		parent := stack[len(stack)-1]
+		parent.Children = append(parent.Children, node)
+		stack = append(stack, node)
+		nodes++
+	}
+	if root == nil {
+		return nil, 0, fmt.Errorf("unknown summary output: %s", strings.TrimSpace(string(output)))
+	}
+	sortSummary(root)
+	return root, dropped, nil
+}
+
+// sortSummary sorts the children of every directory by size, largest first, with "..." last.
+func sortSummary(n *SummaryNode) {
+	sort.SliceStable(n.Children, func(i, j int) bool {
+		a, b := n.Children[i], n.Children[j]
+		if a.Others != b.Others {
+			return b.Others
+		}
+		return a.Size > b.Size
+	})
+	for _, child := range n.Children {
+		sortSummary(child)
+	}
+}
+
+// truncation counts what the depth and entry limits left out of a summary tree.
+type truncation struct {
+	others      int
+	unexpanded  int
+	othersFiles int64
+}
+
+func (t *truncation) walk(n *SummaryNode, depth, maxDepth int) {
+	if n.Others {
+		t.others++
+		t.othersFiles += n.Files
+	}
+	// a directory at the depth limit isn't expanded, but one with nothing in it has nothing left out
+	if depth == maxDepth && n.isDir() && len(n.Children) == 0 && !n.Others && (n.Dirs > 1 || n.Files > 0) {
+		t.unexpanded++
+	}
+	for _, child := range n.Children {
+		t.walk(child, depth+1, maxDepth)
+	}
+}
+
+// topConsumers returns the top n files and directories under root by size, without their children.
+func topConsumers(root *SummaryNode, n int) []SummaryNode {
+	all := []SummaryNode{}
+	var walk func(*SummaryNode)
+	walk = func(node *SummaryNode) {
+		for _, child := range node.Children {
+			if !child.Others {
+				c := *child
+				c.Children = nil
+				all = append(all, c)
+			}
+			walk(child)
+		}
+	}
+	walk(root)
+	sort.SliceStable(all, func(i, j int) bool { return all[i].Size > all[j].Size })
+	if len(all) > n {
+		all = all[:n]
+	}
+	return all
+}
+
+type SummaryArgs struct {
+	Path    string `json:"path" desc:"JuiceFS 挂载点下的目录的绝对路径" mcp:"required"`
+	Depth   int    `json:"depth" desc:"展示的目录树深度，0 表示只统计该目录本身" mcp:"default=2,min=0,max=10"`
+	Entries int    `json:"entries" desc:"每个目录按大小展示的子项个数上限，其余子项合并为 ..." mcp:"default=10,min=1,max=100"`
+	Top     int    `json:"top" desc:"返回占用空间最大的文件和目录个数" mcp:"default=10,min=1,max=100"`
+}
+
+func (j *JuiceFSHandler) handleSummary(
+	ctx context.Context,
+	request mcp.CallToolRequest,
+	args SummaryArgs,
+) (*mcp.CallToolResult, error) {
+	j.log.Debugw("handleSummary", "args", args)
+	path, mount, err := j.mounts.ResolvedMountOf(ctx, args.Path)
+	if err != nil {
+		return nil, fmt.Errorf("summary error: %w", err)
+	}
+	if !mount.Healthy {
+		return nil, fmt.Errorf("summary error: %s is not healthy: %s", mount.Mountpoint, mount.StatfsError)
+	}
+
+	timeoutCtx, cancel := context.WithTimeout(ctx, j.cmdTimeout)
+	defer cancel()
+	cmd := j.exec.CommandContext(timeoutCtx, j.binPath, "summary", "--csv",
+		"--depth", strconv.Itoa(args.Depth), "--entries", strconv.Itoa(args.Entries), path)
+	// the progress of the scan goes to stderr
+	stderr := &bytes.Buffer{}
+	cmd.SetStderr(stderr)
+	out, err := cmd.Output()
+	if errors.Is(timeoutCtx.Err(), context.DeadlineExceeded) {
+		return nil, fmt.Errorf("summary error: not finished in %s, try a subdirectory or a smaller depth", j.cmdTimeout)
+	}
+	if err != nil {
+		msg := strings.TrimSpace(ansiEscape.ReplaceAllString(stderr.String(), ""))
+		j.log.Errorw("exec summary error", "path", path, "err", err, "output", msg)
+		return nil, fmt.Errorf("summary error: %w: %s", err, msg)
+	}
+	root, dropped, err := ParseSummaryOutput(out, maxSummaryNodes)
+	if err != nil {
+		return nil, fmt.Errorf("summary error: %w", err)
+	}
+
+	summary := PathSummary{
+		Path:         path,
+		Mountpoint:   mount.Mountpoint,
+		Depth:        args.Depth,
+		Entries:      args.Entries,
+		Root:         root,
+		TopConsumers: topConsumers(root, args.Top),
+	}
+	t := &truncation{}
+	t.walk(root, 0, args.Depth)
+	notices := []string{}
+	if t.others > 0 {
+		notices = append(notices, fmt.Sprintf("%d directories have more than %d entries, the rest of %d files are summed up as \"...\"",
+			t.others, args.Entries, t.othersFiles))
+	}
+	if t.unexpanded > 0 {
+		notices = append(notices, fmt.Sprintf("%d directories at depth %d are not expanded", t.unexpanded, args.Depth))
+	}
+	if dropped > 0 {
+		notices = append(notices, fmt.Sprintf("%d entries beyond the first %d are left out", dropped, maxSummaryNodes))
+	}
+	if len(notices) > 0 {
+		summary.Truncated = true
+		summary.Notice = strings.Join(notices, "; ") + ", raise depth or entries, or summarize a subdirectory to see more"
+	}
+	res, _ := json.Marshal(summary)
+	return mcp.NewToolResultText(fmt.Sprintf("%+v", string(res))), nil
+}
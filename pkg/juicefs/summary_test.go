@@ -0,0 +1,90 @@
+package juicefs
+
+import (
+	"os"
+	"reflect"
+	"testing"
+)
+
+func readSummaryOutput(t *testing.T, maxNodes int) (*SummaryNode, int) {
+	out, err := os.ReadFile("testdata/summary")
+	if err != nil {
+		t.Fatal(err)
+	}
+	root, dropped, err := ParseSummaryOutput(out, maxNodes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return root, dropped
+}
+
+// paths flattens a summary tree depth first.
+func paths(n *SummaryNode) []string {
+	all := []string{n.Path}
+	for _, child := range n.Children {
+		all = append(all, paths(child)...)
+	}
+	return all
+}
+
+func TestParseSummaryOutput(t *testing.T) {
+	root, dropped := readSummaryOutput(t, 100)
+	if dropped != 0 {
+		t.Errorf("dropped %d", dropped)
+	}
+	if root.Path != "/jfs/data/" || root.Size != 1073758208 || root.Dirs != 4 || root.Files != 7 {
+		t.Errorf("root = %+v", root)
+	}
+	// children are sorted by size with "..." last
+	want := []string{
+		"/jfs/data/",
+		"/jfs/data/big",
+		"/jfs/data/logs/", "/jfs/data/logs/old/", "/jfs/data/logs/...",
+		"/jfs/data/empty/",
+		"/jfs/data/...",
+	}
+	if got := paths(root); !reflect.DeepEqual(got, want) {
+		t.Errorf("tree = %v, want %v", got, want)
+	}
+	if others := root.Children[3]; !others.Others || others.Files != 2 {
+		t.Errorf("last child %+v is not the others", others)
+	}
+
+	top := topConsumers(root, 3)
+	if len(top) != 3 || top[0].Path != "/jfs/data/big" || top[1].Path != "/jfs/data/logs/" || top[2].Path != "/jfs/data/logs/old/" {
+		t.Errorf("top consumers = %+v", top)
+	}
+	if top[1].Children != nil {
+		t.Error("top consumers keep their children")
+	}
+
+	tr := &truncation{}
+	tr.walk(root, 0, 2)
+	// the empty directory has nothing left out
+	if *tr != (truncation{others: 2, unexpanded: 1, othersFiles: 4}) {
+		t.Errorf("truncation = %+v", *tr)
+	}
+}
+
+func TestParseSummaryOutputMaxNodes(t *testing.T) {
+	root, dropped := readSummaryOutput(t, 3)
+	if dropped != 4 {
+		t.Errorf("dropped %d, want 4", dropped)
+	}
+	if got, want := paths(root), []string{"/jfs/data/", "/jfs/data/logs/", "/jfs/data/logs/old/"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("tree = %v, want %v", got, want)
+	}
+}
+
+func TestParseSummaryOutputInvalid(t *testing.T) {
+	for _, out := range []string{
+		"",
+		"PATH,SIZE,DIRS,FILES\n",
+		"PATH,SIZE,DIRS,FILES\n/jfs/,big,1,1\n",
+		"PATH,SIZE\n/jfs/,\"1\n",
+	} {
+		if _, _, err := ParseSummaryOutput([]byte(out), 10); err == nil {
+			t.Errorf("ParseSummaryOutput(%q) succeeded", out)
+		}
+	}
+}
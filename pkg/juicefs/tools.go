@@ -1,130 +1,176 @@
 package juicefs
 
 import (
+	"embed"
+	"os"
+	"time"
+
 	"github.com/mark3labs/mcp-go/mcp"
 	"github.com/mark3labs/mcp-go/server"
 	"go.uber.org/zap"
 	k8sexec "k8s.io/utils/exec"
 
+	"juicefs-mcp/pkg/metrics"
 	"juicefs-mcp/pkg/tools"
 	"juicefs-mcp/pkg/utils/logger"
 )
 
+// Namespace prefixes the names of all JuiceFS tools.
+const Namespace = "juicefs"
+
+// prompts are the MCP prompts of the handler, one front-mattered markdown file per prompt.
+//
+//go:embed prompts/*.md
+var prompts embed.FS
+
 type JuiceFSHandler struct {
 	exec    k8sexec.Interface
 	log     *zap.SugaredLogger
 	binPath string
-	isCE    bool
+	// edition is the configured edition, replaced by the one of the binary once probed
+	edition string
+	// version is the version of the binary, nil if it's not probed
+	version *Version
+	// cmdTimeout bounds the run time of juicefs subprocesses
+	cmdTimeout time.Duration
+	mounts     *MountDiscoverer
+	advisor    *Advisor
+	// benchStore keeps the bench runs, nil if there's no data dir
+	benchStore *BenchStore
+	// rulesDir has the advisor rules merged over the embedded ones, empty if there's none
+	rulesDir string
+}
+
+func NewJuiceFSHandler(binPath, edition string, cmdTimeout time.Duration, dataDir, rulesDir string) *JuiceFSHandler {
+	j := &JuiceFSHandler{
+		exec:       metrics.NewExec(k8sexec.New()),
+		log:        logger.NewLogger("juicefs"),
+		binPath:    binPath,
+		edition:    edition,
+		cmdTimeout: cmdTimeout,
+		mounts:     NewMountDiscoverer(),
+		rulesDir:   rulesDir,
+	}
+	if dataDir != "" {
+		j.benchStore = NewBenchStore(dataDir)
+	}
+	return j
 }
 
-func NewJuiceFSHandler() *JuiceFSHandler {
-	return &JuiceFSHandler{
-		exec:    k8sexec.New(),
-		log:     logger.NewLogger("juicefs"),
-		binPath: "/usr/bin/juicefs",
-		isCE:    true,
+// loadRules loads the embedded advisor rules and the rules of rulesDir over them. The embedded rules
+// are kept alone if rulesDir can't be loaded.
+func (j *JuiceFSHandler) loadRules() ([]Rule, error) {
+	rules, err := LoadRules(ruleFiles, "rules")
+	if err != nil || j.rulesDir == "" {
+		return rules, err
 	}
+	own, err := LoadRules(os.DirFS(j.rulesDir), ".")
+	if err != nil {
+		j.log.Errorw("load advisor rules of rules dir error, using the embedded rules", "rulesDir", j.rulesDir, "error", err)
+		return rules, nil
+	}
+	j.log.Infow("advisor rules loaded", "rulesDir", j.rulesDir, "rules", len(own))
+	return MergeRules(rules, own), nil
 }
 
 func RegisterJuiceFSTools(jfsHandler *JuiceFSHandler) {
+	if err := tools.RegistryPrompts(Namespace, prompts, "prompts"); err != nil {
+		jfsHandler.log.Errorw("register prompts error", "error", err)
+	}
 	// fs
-	tools.RegistryTool(server.ServerTool{
+	tools.RegistryTool(Namespace, tools.TierReadOnly, server.ServerTool{
 		Tool: mcp.NewTool("find_mountpoint",
-			mcp.WithDescription("查看机器上的挂载点"),
+			mcp.WithDescription("查看机器上的 JuiceFS 挂载点，返回挂载点、卷名、挂载 ID、挂载选项、客户端进程 PID 以及挂载点是否可以正常响应"),
 		),
 		Handler: jfsHandler.handleFindMountPoint,
 	})
 	// juicefs
-	tools.RegistryTool(server.ServerTool{
-		Tool: mcp.NewTool("bench_in_juicefs",
-			mcp.WithDescription("通过挂载点进行 juicefs 的性能测试"),
-			mcp.WithString("mountpoint",
-				mcp.Description("挂载点"),
-				mcp.Required(),
-			),
-		),
-		Handler: jfsHandler.handleBench,
-	})
-	tools.RegistryTool(server.ServerTool{
-		Tool: mcp.NewTool("stats_in_juicefs",
-			mcp.WithDescription("通过挂载点实时统计 JuiceFS 性能指标"),
-			mcp.WithString("mountpoint",
-				mcp.Description("挂载点"),
-				mcp.Required(),
-			),
-			mcp.WithNumber("interval",
-				mcp.Description("统计间隔"),
-				mcp.Required(),
-			),
-		),
-		Handler: jfsHandler.handleStats,
-	})
-	tools.RegistryTool(server.ServerTool{
-		Tool: mcp.NewTool("accesslog_in_juicefs",
-			mcp.WithDescription("通过挂载点获取基于文件系统访问日志的实时监控数据"),
-			mcp.WithString("mountpoint",
-				mcp.Description("挂载点"),
-				mcp.Required(),
-			),
-			mcp.WithNumber("interval",
-				mcp.Description("统计间隔"),
-				mcp.Required(),
-			),
-		),
-		Handler: jfsHandler.handleAccessLog,
-	})
-	tools.RegistryTool(server.ServerTool{
-		Tool: mcp.NewTool("get_mount_options",
-			mcp.WithDescription("通过挂载点查看客户端的载参数"),
-			mcp.WithString("mountpoint",
-				mcp.Description("挂载点"),
-				mcp.Required(),
+	// tools running the juicefs binary are registered only if its edition and version have the subcommand
+	if jfsHandler.supports("bench") {
+		tools.RegistryTool(Namespace, tools.TierMutating, tools.NewTypedTool("bench_in_juicefs",
+			"通过挂载点运行 juicefs bench 进行性能测试，可指定块大小、大小文件的大小、小文件个数和并发线程数，返回各阶段的吞吐和延迟。挂载点剩余空间不足以写入测试文件时拒绝执行",
+			jfsHandler.handleBench,
+		))
+	}
+	if jfsHandler.supports("info") {
+		tools.RegistryTool(Namespace, tools.TierReadOnly, tools.NewTypedTool("info_in_juicefs",
+			"运行 juicefs info 查看 JuiceFS 挂载点下文件或目录的详情。文件返回 inode、大小、chunk/slice/block 布局、对象存储中的对象名以及碎片程度（每个 chunk 的 slice 数），目录返回递归的文件数、目录数和总大小",
+			jfsHandler.handleInfo,
+		))
+	}
+	if jfsHandler.supports("summary") {
+		tools.RegistryTool(Namespace, tools.TierReadOnly, tools.NewTypedTool("summary_in_juicefs",
+			"运行 juicefs summary 统计 JuiceFS 挂载点下目录的空间占用，按深度和每个目录的子项个数上限返回各级文件和目录的大小、目录数、文件数组成的树，子项按大小排序，并列出占用最大的文件和目录。超出上限的部分会在返回中说明",
+			jfsHandler.handleSummary,
+		))
+	}
+	if jfsHandler.supports("status") {
+		tools.RegistryTool(Namespace, tools.TierSensitiveRead, tools.NewTypedTool("status_in_juicefs",
+			"通过挂载点客户端的元数据引擎地址运行 juicefs status，返回卷的格式化设置（块大小、压缩算法、回收站天数、对象存储等）和所有客户端会话（主机、PID、挂载点、版本、心跳间隔），标出心跳超时的会话和版本落后的客户端。元数据引擎地址和密码会被隐藏",
+			jfsHandler.handleStatus,
+		))
+	}
+	if jfsHandler.supports("warmup") {
+		tools.RegistryTool(Namespace, tools.TierMutating, tools.NewTypedTool("warmup_in_juicefs",
+			"运行 juicefs warmup 将文件或目录预热到客户端缓存，可指定路径或路径列表文件、并发线程数以及是否后台预热。预热过程中按输出发送进度通知，请求取消时停止预热，返回已缓存的文件数、字节数和失败情况",
+			jfsHandler.handleWarmup,
+		))
+	}
+	if jfsHandler.benchStore != nil {
+		tools.RegistryTool(Namespace, tools.TierReadOnly, tools.NewTypedTool("list_bench_history",
+			"查看保存的 juicefs bench 测试记录，包括测试参数、主机、挂载参数哈希和各阶段的吞吐与延迟，按时间倒序",
+			jfsHandler.handleBenchHistory,
+		))
+		tools.RegistryTool(Namespace, tools.TierReadOnly, tools.NewTypedTool("compare_bench",
+			"将一次 juicefs bench 测试与基线测试逐项对比吞吐和延迟，按基线的均值和标准差判断变化是否显著，标出性能回退的指标",
+			jfsHandler.handleCompareBench,
+		))
+	}
+	tools.RegistryTool(Namespace, tools.TierReadOnly, tools.NewTypedTool("stats_in_juicefs",
+		"通过挂载点的 .stats 文件按间隔采样 JuiceFS 性能指标，返回每次采样的结构化数据、各列的最小值、平均值、最大值及延迟分布",
+		jfsHandler.handleStats,
+	))
+	tools.RegistryTool(Namespace, tools.TierSensitiveRead, tools.NewTypedTool("accesslog_in_juicefs",
+		"通过挂载点采集文件系统访问日志，按操作统计次数、错误数和延迟分位数，以及访问最多的 inode、路径和进程，可按操作、inode 或进程过滤并返回原始日志行",
+		jfsHandler.handleAccessLog,
+	))
+	tools.RegistryTool(Namespace, tools.TierReadOnly, tools.NewTypedTool("detect_read_amplification",
+		"通过挂载点的访问日志和监控指标检测读放大：按文件句柄分析读请求的顺序/随机比例、平均大小和 offset 跳跃距离，结合对象存储下载流量给出结论和挂载参数建议",
+		jfsHandler.handleDetectReadAmplification,
+	))
+	tools.RegistryTool(Namespace, tools.TierSensitiveRead, tools.NewTypedTool("detect_write_fragmentation",
+		"通过挂载点的访问日志、监控指标和挂载参数检测低速顺序写带来的碎片：找出写入慢、产生大量 slice 的文件，结合对象存储上传和碎片合并流量给出结论和 --flush-wait 建议",
+		jfsHandler.handleDetectWriteFragmentation,
+	))
+	tools.RegistryTool(Namespace, tools.TierSensitiveRead, tools.NewTypedTool("get_mount_options",
+		"通过挂载点查看客户端的挂载参数，包括缓存、缓冲区、预读、回写、元数据缓存等选项及其中与默认值不同的选项，元数据引擎地址中的密码会被隐藏",
+		jfsHandler.handleFindMountOptions,
+	))
+	// advisor
+	if rules, err := jfsHandler.loadRules(); err != nil {
+		jfsHandler.log.Errorw("load advisor rules error", "error", err)
+	} else {
+		jfsHandler.advisor = NewAdvisor(rules)
+		tools.RegistryTool(Namespace, tools.TierReadOnly, tools.NewTypedTool("advise_tuning",
+			"根据挂载参数、.stats 监控指标和缓存盘信息，按内置和规则目录中自定义的调优规则检查 JuiceFS 客户端配置，返回每条问题的严重程度、依据和建议的挂载参数",
+			jfsHandler.handleAdvise,
+		))
+	}
+	// docs
+	for _, d := range docs {
+		tools.RegistryResource(server.ServerResource{
+			Resource: mcp.NewResource(docURI(d.topic), d.name,
+				mcp.WithResourceDescription(d.description),
+				mcp.WithMIMEType("text/markdown"),
 			),
+			Handler: jfsHandler.handleReadDoc,
+		})
+	}
+	tools.RegistryResourceTemplate(tools.ResourceTemplate{
+		Template: mcp.NewResourceTemplate(docURI("{topic}"), "JuiceFS 调优文档",
+			mcp.WithTemplateDescription("JuiceFS 的调优文档，topic 可选 read-amplification、write-amplification、meta-cache、data-cache、buffer-size、readahead-prefetch"),
+			mcp.WithTemplateMIMEType("text/markdown"),
 		),
-		Handler: jfsHandler.handleFindMountOptions,
+		Handler: jfsHandler.handleReadDoc,
 	})
-	//// docs
-	//tools.RegistryTool(server.ServerTool{
-	//	Tool: mcp.NewTool("how_to_diagnosis_read_amplification",
-	//		mcp.WithDescription("什么是 JuiceFS 的读放大问题，以及应该如何优化"),
-	//	),
-	//	Handler: handleDocsOfReadAmplification,
-	//})
-	//tools.RegistryTool(server.ServerTool{
-	//	Tool: mcp.NewTool("how_to_diagnosis_write_amplification",
-	//		mcp.WithDescription("什么是 JuiceFS 的写放大问题，以及应该如何优化"),
-	//	),
-	//	Handler: handleDocsOfWriteAmplification,
-	//})
-	//tools.RegistryTool(server.ServerTool{
-	//	Tool: mcp.NewTool("what_is_meta_cache",
-	//		mcp.WithDescription("元数据缓存的作用，以及如何调优"),
-	//	),
-	//	Handler: handleDocsOfMetaCache,
-	//})
-	//tools.RegistryTool(server.ServerTool{
-	//	Tool: mcp.NewTool("what_is_data_cache",
-	//		mcp.WithDescription("数据缓存的作用，以及如何调优，包括内核页缓存、内核回写模式、客户端读缓存、客户端写缓存。"),
-	//	),
-	//	Handler: handleDocsOfDataCache,
-	//})
-	//tools.RegistryTool(server.ServerTool{
-	//	Tool: mcp.NewTool("what_is_read_ahead_and_prefetch",
-	//		mcp.WithDescription("什么是 JuiceFS 的预读和预取"),
-	//	),
-	//	Handler: handleDocsOfReadAheadAndPrefetch,
-	//})
-	//
-	//tools.RegistryTool(server.ServerTool{
-	//	Tool: mcp.NewTool("what_is_buffer_size",
-	//		mcp.WithDescription("buffer-size 的对读写数据的作用及其调优方法"),
-	//	),
-	//	Handler: handleDocsOfBufferSize,
-	//})
-	//tools.RegistryTool(server.ServerTool{
-	//	Tool: mcp.NewTool("what_is_read_ahead_and_prefetch",
-	//		mcp.WithDescription("什么是 JuiceFS 的预读和预取"),
-	//	),
-	//	Handler: handleDocsOfReadAheadAndPrefetch,
-	//})
 }
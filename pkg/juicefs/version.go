@@ -0,0 +1,119 @@
+package juicefs
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// editions of JuiceFS
+const (
+	EditionCE = "ce"
+	EditionEE = "ee"
+)
+
+// probeTimeout bounds `juicefs version` at startup.
+const probeTimeout = 10 * time.Second
+
+// Version is the version of the juicefs binary.
+type Version struct {
+	Edition string `json:"edition"`
+	Version string `json:"version"`
+	Major   int    `json:"-"`
+	Minor   int    `json:"-"`
+	Patch   int    `json:"-"`
+	// Raw is the output of `juicefs version`
+	Raw string `json:"raw"`
+}
+
+// versionLine matches the output of `juicefs version`, e.g.
+//
+//	juicefs version 1.1.2+2024-02-04.8dbd89a
+//	JuiceFS version 5.0.10 (2024-03-12 6a8e3f0)
+var versionLine = regexp.MustCompile(`(?i)version\s+v?(\d+)\.(\d+)(?:\.(\d+))?\S*`)
+
+// ParseVersion parses the output of `juicefs version`. The enterprise edition is numbered from 4.0,
+// while the community edition is still 1.x.
+func ParseVersion(output string) (*Version, error) {
+	output = strings.TrimSpace(output)
+	m := versionLine.FindStringSubmatch(output)
+	if m == nil {
+		return nil, fmt.Errorf("unknown version %q", output)
+	}
+	v := &Version{Raw: output}
+	v.Major, _ = strconv.Atoi(m[1])
+	v.Minor, _ = strconv.Atoi(m[2])
+	v.Patch, _ = strconv.Atoi(m[3])
+	v.Version = fmt.Sprintf("%d.%d.%d", v.Major, v.Minor, v.Patch)
+	v.Edition = EditionCE
+	if v.Major >= 4 || strings.Contains(strings.ToLower(output), "enterprise") {
+		v.Edition = EditionEE
+	}
+	return v, nil
+}
+
+// AtLeast returns whether v is major.minor or later.
+func (v *Version) AtLeast(major, minor int) bool {
+	return v.Major > major || v.Major == major && v.Minor >= minor
+}
+
+// subcommands are the juicefs subcommands run by the tools, with the first version of each edition
+// having them. An edition not listed doesn't have the subcommand.
+var subcommands = map[string]map[string][2]int{
+	"bench":   {EditionCE: {0, 13}, EditionEE: {4, 0}},
+	"info":    {EditionCE: {0, 13}, EditionEE: {4, 0}},
+	"status":  {EditionCE: {0, 13}},
+	"summary": {EditionCE: {1, 1}},
+	"warmup":  {EditionCE: {0, 13}, EditionEE: {4, 0}},
+}
+
+// ProbeVersion runs `juicefs version` with the configured binary and records its edition and version.
+// The configured edition is kept if the binary can't be run.
+func (j *JuiceFSHandler) ProbeVersion(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, probeTimeout)
+	defer cancel()
+	out, err := j.exec.CommandContext(ctx, j.binPath, "version").CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("run %s version error: %w", j.binPath, err)
+	}
+	v, err := ParseVersion(string(out))
+	if err != nil {
+		return err
+	}
+	if v.Edition != j.edition {
+		j.log.Warnw("edition of juicefs binary differs from the configured one, using the binary's",
+			"binPath", j.binPath, "configured", j.edition, "detected", v.Edition)
+		j.edition = v.Edition
+	}
+	j.version = v
+	j.log.Infow("juicefs binary probed", "binPath", j.binPath, "edition", v.Edition, "version", v.Version)
+	return nil
+}
+
+// supports returns whether the probed binary has the subcommand.
+func (j *JuiceFSHandler) supports(subcommand string) bool {
+	if j.version == nil {
+		return false
+	}
+	since, ok := subcommands[subcommand][j.version.Edition]
+	return ok && j.version.AtLeast(since[0], since[1])
+}
+
+// eeFlagNames are the mount flags named differently by the enterprise edition.
+var eeFlagNames = map[string]string{
+	"attr-cache":      "attrcacheto",
+	"entry-cache":     "entrycacheto",
+	"dir-entry-cache": "direntrycacheto",
+	"open-cache":      "opencache",
+}
+
+// flagName returns the name of a mount flag in the edition of the client.
+func (j *JuiceFSHandler) flagName(name string) string {
+	if ee, ok := eeFlagNames[name]; ok && j.edition == EditionEE {
+		return ee
+	}
+	return name
+}
@@ -0,0 +1,88 @@
+package juicefs
+
+import "testing"
+
+func TestParseVersion(t *testing.T) {
+	tests := []struct {
+		output  string
+		edition string
+		version string
+	}{
+		{"juicefs version 1.1.2+2024-02-04.8dbd89a", EditionCE, "1.1.2"},
+		{"juicefs version 1.0.0-beta3+2022-05-05.0fb9155\n", EditionCE, "1.0.0"},
+		{"juicefs version 0.17.5+2021-12-10.4ab5b8f", EditionCE, "0.17.5"},
+		{"juicefs version v1.2", EditionCE, "1.2.0"},
+		// warnings may come before the version
+		{"2024/01/01 10:00:00.000000 juicefs[1] <WARNING>: no config found\njuicefs version 1.2.0+2024-06-18.873c47b", EditionCE, "1.2.0"},
+		{"JuiceFS version 5.0.10 (2024-03-12 6a8e3f0)", EditionEE, "5.0.10"},
+		{"JuiceFS version 4.9.21 (2023-04-12 c1a7f1c)", EditionEE, "4.9.21"},
+		{"JuiceFS Enterprise Edition version 1.0.0", EditionEE, "1.0.0"},
+	}
+	for _, tt := range tests {
+		v, err := ParseVersion(tt.output)
+		if err != nil {
+			t.Errorf("ParseVersion(%q) error: %v", tt.output, err)
+			continue
+		}
+		if v.Edition != tt.edition || v.Version != tt.version {
+			t.Errorf("ParseVersion(%q) = %s %s, want %s %s", tt.output, v.Edition, v.Version, tt.edition, tt.version)
+		}
+	}
+	for _, output := range []string{"", "sh: juicefs: command not found", "juicefs version dev"} {
+		if v, err := ParseVersion(output); err == nil {
+			t.Errorf("ParseVersion(%q) = %+v", output, v)
+		}
+	}
+}
+
+func TestSupports(t *testing.T) {
+	tests := []struct {
+		output      string
+		supported   []string
+		unsupported []string
+	}{
+		{"juicefs version 1.1.2+2024-02-04.8dbd89a", []string{"bench", "info", "status", "summary", "warmup"}, []string{"unknown"}},
+		// summary came with 1.1
+		{"juicefs version 1.0.4+2023-04-06.f1c475d", []string{"bench", "status", "warmup"}, []string{"summary"}},
+		{"juicefs version 0.12.1 (2021-04-15 7e10e75)", nil, []string{"bench", "info", "status", "warmup"}},
+		// the enterprise edition has no status nor summary
+		{"JuiceFS version 5.0.10 (2024-03-12 6a8e3f0)", []string{"bench", "info", "warmup"}, []string{"status", "summary"}},
+	}
+	for _, tt := range tests {
+		v, err := ParseVersion(tt.output)
+		if err != nil {
+			t.Fatal(err)
+		}
+		j := &JuiceFSHandler{edition: v.Edition, version: v}
+		for _, sub := range tt.supported {
+			if !j.supports(sub) {
+				t.Errorf("%s doesn't support %s", v.Raw, sub)
+			}
+		}
+		for _, sub := range tt.unsupported {
+			if j.supports(sub) {
+				t.Errorf("%s supports %s", v.Raw, sub)
+			}
+		}
+	}
+	// nothing is run with a binary not probed
+	if (&JuiceFSHandler{edition: EditionCE}).supports("info") {
+		t.Error("unprobed binary supports info")
+	}
+}
+
+func TestFlagName(t *testing.T) {
+	for _, tt := range []struct {
+		edition, name, want string
+	}{
+		{EditionCE, "attr-cache", "attr-cache"},
+		{EditionEE, "attr-cache", "attrcacheto"},
+		{EditionEE, "open-cache", "opencache"},
+		{EditionEE, "cache-size", "cache-size"},
+	} {
+		j := &JuiceFSHandler{edition: tt.edition}
+		if got := j.flagName(tt.name); got != tt.want {
+			t.Errorf("flagName(%s) of %s = %s, want %s", tt.name, tt.edition, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,274 @@
+package juicefs
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"k8s.io/apimachinery/pkg/util/json"
+)
+
+const (
+	// progressInterval is the least time between two progress notifications.
+	progressInterval = time.Second
+	// maxWarmupFailures bounds the failure messages kept in the result.
+	maxWarmupFailures = 20
+	// warmupOutputTail is how much of the output is kept for errors.
+	warmupOutputTail = 4096
+)
+
+type WarmupArgs struct {
+	Paths      []string `json:"paths" desc:"要预热的文件或目录的绝对路径，必须位于 JuiceFS 挂载点下，目录会递归预热"`
+	FileList   string   `json:"fileList" desc:"包含要预热路径的文件的绝对路径，每行一个路径，该文件须与 paths 位于同一 JuiceFS 挂载点下，可与 paths 同时使用"`
+	Threads    int      `json:"threads" desc:"并发线程数" mcp:"default=50,min=1,max=255"`
+	Background bool     `json:"background" desc:"是否在挂载进程中后台预热，后台预热会立即返回，无法取消，也没有进度和结果"`
+	Timeout    int      `json:"timeout" desc:"预热的超时时间，单位秒，0 表示使用服务端配置的命令超时时间" mcp:"default=0,min=0,max=86400"`
+}
+
+// WarmupResult is the outcome of a `juicefs warmup` run.
+type WarmupResult struct {
+	Mountpoint string   `json:"mountpoint"`
+	Paths      []string `json:"paths,omitempty"`
+	FileList   string   `json:"fileList,omitempty"`
+	Threads    int      `json:"threads"`
+	Background bool     `json:"background"`
+	// Files and CachedBytes are what was warmed up, as last reported by juicefs
+	Files       int64 `json:"files"`
+	TotalFiles  int64 `json:"totalFiles,omitempty"`
+	CachedBytes int64 `json:"cachedBytes"`
+	FailedFiles int64 `json:"failedFiles"`
+	// Failures are the warnings and errors printed for files that failed
+	Failures    []string `json:"failures,omitempty"`
+	Cancelled   bool     `json:"cancelled,omitempty"`
+	DurationSec float64  `json:"durationSec"`
+	// Output is the raw output, kept only if nothing could be parsed
+	Output string `json:"output,omitempty"`
+}
+
+var (
+	// terminalEscape matches the escape sequences progress bars draw with.
+	terminalEscape = regexp.MustCompile(`\x1b\[[0-9;?]*[A-Za-z]`)
+	// warmupCount matches the count bar, e.g. "Warming up count: 5/12" or "Warming up count: 5".
+	warmupCount = regexp.MustCompile(`(?i)warm\w*\s+up[\w ]*?count:\s*(\d+)(?:\s*/\s*(\d+))?`)
+	// warmupBytes matches the bytes bar, e.g. "Warming up bytes: 32.00 MiB (33554432 Bytes)".
+	warmupBytes = regexp.MustCompile(`(?i)warm\w*\s+up[\w ]*?bytes:\s*([\d.]+\s*[KMGTP]?i?B?(?:\s*\(\d+ Bytes\))?)`)
+	// warmupDone matches the summary, e.g. "Successfully warmed up 12 files (33554432 bytes)".
+	warmupDone = regexp.MustCompile(`(?i)warmed\s+up\s+(\d+)\s+(?:files|paths)\s*\(([^)]*)\)`)
+	// warmupFailed matches the count of failures, e.g. "Failed to warm up 2 files".
+	warmupFailed = regexp.MustCompile(`(?i)failed\s+to\s+warm\s*up\s+(\d+)`)
+	// logLevel matches the level of a juicefs log line.
+	logLevel = regexp.MustCompile(`<(WARNING|ERROR|FATAL)>:\s*(.*)`)
+)
+
+// parseLine updates the result with a line of warmup output, it returns whether the progress changed.
+func (r *WarmupResult) parseLine(line string) bool {
+	files, total, cached := r.Files, r.TotalFiles, r.CachedBytes
+	if m := warmupCount.FindStringSubmatch(line); m != nil {
+		r.Files, _ = strconv.ParseInt(m[1], 10, 64)
+		if m[2] != "" {
+			r.TotalFiles, _ = strconv.ParseInt(m[2], 10, 64)
+		}
+	}
+	if m := warmupBytes.FindStringSubmatch(line); m != nil {
+		if v, err := parseInfoBytes(strings.TrimSpace(m[1])); err == nil {
+			r.CachedBytes = v
+		}
+	}
+	if m := warmupDone.FindStringSubmatch(line); m != nil {
+		r.Files, _ = strconv.ParseInt(m[1], 10, 64)
+		size := strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(m[2]), "bytes"))
+		if v, err := parseInfoBytes(size); err == nil {
+			r.CachedBytes = v
+		}
+	}
+	if m := warmupFailed.FindStringSubmatch(line); m != nil {
+		r.FailedFiles, _ = strconv.ParseInt(m[1], 10, 64)
+	}
+	if m := logLevel.FindStringSubmatch(line); m != nil && len(r.Failures) < maxWarmupFailures {
+		r.Failures = append(r.Failures, strings.TrimSpace(m[2]))
+	}
+	return r.Files != files || r.TotalFiles != total || r.CachedBytes != cached
+}
+
+func (r *WarmupResult) parsed() bool {
+	return r.Files > 0 || r.CachedBytes > 0 || r.FailedFiles > 0
+}
+
+// scanTerminalLines splits output into lines ended by \n or by the \r progress bars redraw with.
+func scanTerminalLines(data []byte, atEOF bool) (advance int, token []byte, err error) {
+	if i := bytes.IndexAny(data, "\r\n"); i >= 0 {
+		return i + 1, data[:i], nil
+	}
+	if atEOF && len(data) > 0 {
+		return len(data), data, nil
+	}
+	return 0, nil, nil
+}
+
+// notifyProgress sends a progress notification if the client asked for them with a progress token.
+func notifyProgress(ctx context.Context, request mcp.CallToolRequest, progress, total float64, message string) {
+	if request.Params.Meta == nil || request.Params.Meta.ProgressToken == nil {
+		return
+	}
+	srv := server.ServerFromContext(ctx)
+	if srv == nil {
+		return
+	}
+	params := map[string]any{
+		"progressToken": request.Params.Meta.ProgressToken,
+		"progress":      progress,
+		"message":       message,
+	}
+	if total > 0 {
+		params["total"] = total
+	}
+	_ = srv.SendNotificationToClient(ctx, "notifications/progress", params)
+}
+
+// warmupMessage describes the progress of r.
+func (r *WarmupResult) warmupMessage() string {
+	msg := fmt.Sprintf("warmed up %d files, %s", r.Files, humanize(float64(r.CachedBytes), "B"))
+	if r.FailedFiles > 0 {
+		msg += fmt.Sprintf(", %d failed", r.FailedFiles)
+	}
+	return msg
+}
+
+// warmupMount returns paths with their symlinks resolved and the mount they are all in, juicefs warms
+// up paths of a single mount at once.
+func (j *JuiceFSHandler) warmupMount(ctx context.Context, paths []string) ([]string, *Mount, error) {
+	var mount *Mount
+	resolved := make([]string, 0, len(paths))
+	for _, path := range paths {
+		path, m, err := j.mounts.ResolvedMountOf(ctx, path)
+		if err != nil {
+			return nil, nil, err
+		}
+		if mount != nil && m.Mountpoint != mount.Mountpoint {
+			return nil, nil, fmt.Errorf("%s and %s are in different mounts", mount.Mountpoint, m.Mountpoint)
+		}
+		resolved, mount = append(resolved, path), m
+	}
+	return resolved, mount, nil
+}
+
+func (j *JuiceFSHandler) handleWarmup(
+	ctx context.Context,
+	request mcp.CallToolRequest,
+	args WarmupArgs,
+) (*mcp.CallToolResult, error) {
+	j.log.Debugw("handleWarmup", "args", args)
+	paths := append([]string{}, args.Paths...)
+	if args.FileList != "" {
+		// the list is never read here, juicefs reads it from the mount it must be in, so no file
+		// outside JuiceFS can be read through the tool
+		paths = append(paths, args.FileList)
+	}
+	if len(paths) == 0 {
+		return nil, fmt.Errorf("warmup error: no paths given")
+	}
+	paths, mount, err := j.warmupMount(ctx, paths)
+	if err != nil {
+		return nil, fmt.Errorf("warmup error: %w", err)
+	}
+	if !mount.Healthy {
+		return nil, fmt.Errorf("warmup error: %s is not healthy: %s", mount.Mountpoint, mount.StatfsError)
+	}
+
+	cmdArgs := []string{"warmup", "--threads", strconv.Itoa(args.Threads)}
+	if args.Background {
+		cmdArgs = append(cmdArgs, "--background")
+	}
+	if args.FileList != "" {
+		// the file list is resolved last
+		cmdArgs = append(cmdArgs, "--file", paths[len(paths)-1])
+		paths = paths[:len(paths)-1]
+	}
+	cmdArgs = append(cmdArgs, paths...)
+
+	timeout := j.cmdTimeout
+	if args.Timeout > 0 {
+		timeout = time.Second * time.Duration(args.Timeout)
+	}
+	timeoutCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	cmd := j.exec.CommandContext(timeoutCtx, j.binPath, cmdArgs...)
+	// progress bars and logs go to stderr, both are parsed
+	reader, writer := io.Pipe()
+	cmd.SetStdout(writer)
+	cmd.SetStderr(writer)
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("warmup error: %w", err)
+	}
+	waitErr := make(chan error, 1)
+	go func() {
+		err := cmd.Wait()
+		writer.Close()
+		waitErr <- err
+	}()
+
+	result := &WarmupResult{
+		Mountpoint: mount.Mountpoint,
+		Paths:      args.Paths,
+		FileList:   args.FileList,
+		Threads:    args.Threads,
+		Background: args.Background,
+	}
+	start, notified := time.Now(), time.Time{}
+	notifyProgress(ctx, request, 0, 0, "warmup started")
+	tail := &bytes.Buffer{}
+	scanner := bufio.NewScanner(reader)
+	scanner.Split(scanTerminalLines)
+	for scanner.Scan() {
+		line := strings.TrimSpace(terminalEscape.ReplaceAllString(scanner.Text(), ""))
+		if line == "" {
+			continue
+		}
+		tail.WriteString(line + "\n")
+		if tail.Len() > warmupOutputTail {
+			tail.Next(tail.Len() - warmupOutputTail)
+		}
+		if result.parseLine(line) && time.Since(notified) >= progressInterval {
+			notifyProgress(ctx, request, float64(result.Files), float64(result.TotalFiles), result.warmupMessage())
+			notified = time.Now()
+		}
+	}
+	// drain what's left if the scanner gave up on a too long line
+	_, _ = io.Copy(io.Discard, reader)
+	err = <-waitErr
+	result.DurationSec = time.Since(start).Seconds()
+	output := strings.TrimSpace(tail.String())
+
+	switch {
+	case errors.Is(ctx.Err(), context.Canceled):
+		j.log.Infow("warmup cancelled", "mountpoint", mount.Mountpoint, "files", result.Files, "cachedBytes", result.CachedBytes)
+		result.Cancelled = true
+	case errors.Is(timeoutCtx.Err(), context.DeadlineExceeded):
+		j.log.Errorw("warmup timeout", "mountpoint", mount.Mountpoint, "timeout", timeout)
+		return nil, fmt.Errorf("warmup error: not finished in %s, %s", timeout, result.warmupMessage())
+	case err != nil && !result.parsed():
+		j.log.Errorw("exec warmup error", "mountpoint", mount.Mountpoint, "err", err, "output", output)
+		return nil, fmt.Errorf("warmup error: %w: %s", err, output)
+	case err != nil:
+		// juicefs exits with an error if some files failed, what was warmed up is still reported
+		j.log.Warnw("warmup finished with failures", "mountpoint", mount.Mountpoint, "err", err)
+		if result.FailedFiles == 0 {
+			result.FailedFiles = int64(len(result.Failures))
+		}
+	}
+	if !result.parsed() && !args.Background {
+		result.Output = output
+	}
+	notifyProgress(ctx, request, float64(result.Files), float64(result.TotalFiles), result.warmupMessage())
+	res, _ := json.Marshal(result)
+	return mcp.NewToolResultText(fmt.Sprintf("%+v", string(res))), nil
+}
@@ -0,0 +1,81 @@
+package juicefs
+
+import (
+	"bufio"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestWarmupOutput(t *testing.T) {
+	f, err := os.Open("testdata/warmup")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	result := &WarmupResult{}
+	type progress struct{ files, total, cached int64 }
+	progresses := []progress{}
+	scanner := bufio.NewScanner(f)
+	scanner.Split(scanTerminalLines)
+	for scanner.Scan() {
+		line := strings.TrimSpace(terminalEscape.ReplaceAllString(scanner.Text(), ""))
+		if line != "" && result.parseLine(line) {
+			progresses = append(progresses, progress{result.Files, result.TotalFiles, result.CachedBytes})
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		t.Fatal(err)
+	}
+
+	// every redraw of a progress bar is a line
+	want := []progress{
+		{0, 12, 0},
+		{5, 12, 0},
+		{5, 12, 32 << 20},
+		{12, 12, 32 << 20},
+		{12, 12, 48 << 20},
+	}
+	if !reflect.DeepEqual(progresses, want) {
+		t.Errorf("progress = %v, want %v", progresses, want)
+	}
+	if !result.parsed() || result.Files != 12 || result.CachedBytes != 48<<20 || result.FailedFiles != 2 {
+		t.Errorf("result = %+v", result)
+	}
+	failures := []string{
+		"failed to warm up /jfs/data/bad: input/output error [warmup.go:80]",
+		"Failed to warm up 2 files [warmup.go:240]",
+	}
+	if !reflect.DeepEqual(result.Failures, failures) {
+		t.Errorf("failures = %q, want %q", result.Failures, failures)
+	}
+	if msg := result.warmupMessage(); msg != "warmed up 12 files, 48.0M, 2 failed" {
+		t.Errorf("message = %q", msg)
+	}
+}
+
+func TestWarmupOutputUnparsed(t *testing.T) {
+	result := &WarmupResult{}
+	for _, line := range []string{"", "usage: juicefs warmup [options] PATH ...", "Warming up count: abc"} {
+		if result.parseLine(line) {
+			t.Errorf("progress from %q", line)
+		}
+	}
+	if result.parsed() {
+		t.Errorf("result parsed from nothing: %+v", result)
+	}
+}
+
+func TestScanTerminalLines(t *testing.T) {
+	scanner := bufio.NewScanner(strings.NewReader("a\rb\r\nc\nd"))
+	scanner.Split(scanTerminalLines)
+	lines := []string{}
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+	}
+	if want := []string{"a", "b", "", "c", "d"}; !reflect.DeepEqual(lines, want) {
+		t.Errorf("lines = %q, want %q", lines, want)
+	}
+}
@@ -0,0 +1,92 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	k8sexec "k8s.io/utils/exec"
+)
+
+// exec wraps a k8sexec.Interface to record the exit codes and run time of the subprocesses.
+type exec struct {
+	k8sexec.Interface
+}
+
+func NewExec(inner k8sexec.Interface) k8sexec.Interface {
+	return &exec{Interface: inner}
+}
+
+func (e *exec) Command(cmd string, args ...string) k8sexec.Cmd {
+	return &command{Cmd: e.Interface.Command(cmd, args...), name: filepath.Base(cmd)}
+}
+
+func (e *exec) CommandContext(ctx context.Context, cmd string, args ...string) k8sexec.Cmd {
+	return &command{Cmd: e.Interface.CommandContext(ctx, cmd, args...), name: filepath.Base(cmd)}
+}
+
+type command struct {
+	k8sexec.Cmd
+	name  string
+	start time.Time
+}
+
+func (c *command) begin() {
+	c.start = time.Now()
+	subprocessInFlight.WithLabelValues(c.name).Inc()
+}
+
+func (c *command) end(err error) {
+	subprocessInFlight.WithLabelValues(c.name).Dec()
+	subprocessDuration.WithLabelValues(c.name).Observe(time.Since(c.start).Seconds())
+	subprocessTotal.WithLabelValues(c.name, exitCode(err)).Inc()
+}
+
+func exitCode(err error) string {
+	if err == nil {
+		return "0"
+	}
+	var exitErr k8sexec.ExitError
+	if errors.As(err, &exitErr) && exitErr.Exited() {
+		return strconv.Itoa(exitErr.ExitStatus())
+	}
+	return "-1"
+}
+
+func (c *command) Run() error {
+	c.begin()
+	err := c.Cmd.Run()
+	c.end(err)
+	return err
+}
+
+func (c *command) CombinedOutput() ([]byte, error) {
+	c.begin()
+	out, err := c.Cmd.CombinedOutput()
+	c.end(err)
+	return out, err
+}
+
+func (c *command) Output() ([]byte, error) {
+	c.begin()
+	out, err := c.Cmd.Output()
+	c.end(err)
+	return out, err
+}
+
+func (c *command) Start() error {
+	c.begin()
+	err := c.Cmd.Start()
+	if err != nil {
+		c.end(err)
+	}
+	return err
+}
+
+func (c *command) Wait() error {
+	err := c.Cmd.Wait()
+	c.end(err)
+	return err
+}
@@ -0,0 +1,120 @@
+package metrics
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "juicefs_mcp"
+
+var (
+	registry = prometheus.NewRegistry()
+
+	toolCalls = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "tool_calls_total",
+		Help:      "Total number of tool calls.",
+	}, []string{"tool"})
+	toolErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "tool_errors_total",
+		Help:      "Total number of tool calls that failed or returned a tool error.",
+	}, []string{"tool"})
+	toolDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "tool_duration_seconds",
+		Help:      "Latency of tool calls.",
+		Buckets:   prometheus.ExponentialBuckets(0.005, 2, 14),
+	}, []string{"tool"})
+	toolInFlight = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "tool_in_flight",
+		Help:      "Number of tool calls in progress.",
+	}, []string{"tool"})
+
+	kubeRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "kube_request_duration_seconds",
+		Help:      "Latency of Kubernetes API requests.",
+		Buckets:   prometheus.ExponentialBuckets(0.005, 2, 12),
+	}, []string{"verb", "code"})
+
+	subprocessTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "subprocess_total",
+		Help:      "Total number of subprocesses by command and exit code, -1 if it failed to start or was killed.",
+	}, []string{"command", "exit_code"})
+	subprocessDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "subprocess_duration_seconds",
+		Help:      "Run time of subprocesses.",
+		Buckets:   prometheus.ExponentialBuckets(0.01, 2, 14),
+	}, []string{"command"})
+	subprocessInFlight = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "subprocess_in_flight",
+		Help:      "Number of subprocesses running.",
+	}, []string{"command"})
+)
+
+func init() {
+	registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		toolCalls, toolErrors, toolDuration, toolInFlight,
+		kubeRequestDuration,
+		subprocessTotal, subprocessDuration, subprocessInFlight,
+	)
+}
+
+// Handler serves the metrics of the server in the Prometheus format.
+func Handler() http.Handler {
+	return promhttp.HandlerFor(registry, promhttp.HandlerOpts{})
+}
+
+// ToolMiddleware counts and times every call of the tools it wraps.
+func ToolMiddleware(next server.ToolHandlerFunc) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		tool := request.Params.Name
+		toolCalls.WithLabelValues(tool).Inc()
+		toolInFlight.WithLabelValues(tool).Inc()
+		defer toolInFlight.WithLabelValues(tool).Dec()
+
+		start := time.Now()
+		result, err := next(ctx, request)
+		toolDuration.WithLabelValues(tool).Observe(time.Since(start).Seconds())
+		if err != nil || (result != nil && result.IsError) {
+			toolErrors.WithLabelValues(tool).Inc()
+		}
+		return result, err
+	}
+}
+
+// kubeTransport times the requests to the Kubernetes API.
+type kubeTransport struct {
+	next http.RoundTripper
+}
+
+// WrapKubeTransport is a rest.Config WrapTransport that times the requests to the Kubernetes API.
+func WrapKubeTransport(rt http.RoundTripper) http.RoundTripper {
+	return &kubeTransport{next: rt}
+}
+
+func (t *kubeTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	start := time.Now()
+	resp, err := t.next.RoundTrip(req)
+	code := "error"
+	if err == nil {
+		code = strconv.Itoa(resp.StatusCode)
+	}
+	kubeRequestDuration.WithLabelValues(req.Method, code).Observe(time.Since(start).Seconds())
+	return resp, err
+}
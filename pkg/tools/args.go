@@ -0,0 +1,322 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// TypedHandlerFunc handles a tool call with its arguments decoded into Args.
+type TypedHandlerFunc[Args any] func(ctx context.Context, request mcp.CallToolRequest, args Args) (*mcp.CallToolResult, error)
+
+// NewTypedTool builds a tool whose input schema is generated from the fields of Args, and whose
+// arguments are decoded and validated before handler runs. Fields are declared like:
+//
+//	type PodLogArgs struct {
+//		PodName   string `json:"podName" desc:"Pod 名称" mcp:"required"`
+//		TailLines int64  `json:"tailLines" desc:"获取的日志行数" mcp:"default=20,min=1,max=10000"`
+//		Order     string `json:"order" desc:"排序" mcp:"enum=asc|desc"`
+//	}
+//
+// Supported field kinds are string, bool, integers, floats and slices of them.
+// Invalid arguments are returned to the client as tool errors.
+func NewTypedTool[Args any](name, description string, handler TypedHandlerFunc[Args]) server.ServerTool {
+	fields, err := parseArgFields(reflect.TypeFor[Args]())
+	if err != nil {
+		panic(fmt.Sprintf("invalid args of tool %s: %v", name, err))
+	}
+	tool := mcp.NewTool(name, mcp.WithDescription(description))
+	for _, f := range fields {
+		tool.InputSchema.Properties[f.name] = f.schema()
+		if f.required {
+			tool.InputSchema.Required = append(tool.InputSchema.Required, f.name)
+		}
+	}
+	return server.ServerTool{
+		Tool: tool,
+		Handler: func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+			var args Args
+			if err := decodeArgs(fields, request.GetArguments(), reflect.ValueOf(&args).Elem()); err != nil {
+				return mcp.NewToolResultError(fmt.Sprintf("invalid arguments: %v", err)), nil
+			}
+			return handler(ctx, request, args)
+		},
+	}
+}
+
+type argField struct {
+	index       int
+	name        string
+	description string
+	typ         reflect.Type
+	required    bool
+	defaultVal  string
+	hasDefault  bool
+	min, max    *float64
+	enum        []string
+}
+
+func parseArgFields(t reflect.Type) ([]argField, error) {
+	if t.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("args must be a struct, got %s", t)
+	}
+	fields := []argField{}
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if !sf.IsExported() {
+			continue
+		}
+		name, _, _ := strings.Cut(sf.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = sf.Name
+		}
+		f := argField{index: i, name: name, description: sf.Tag.Get("desc"), typ: sf.Type}
+		if _, err := jsonType(sf.Type); err != nil {
+			return nil, fmt.Errorf("field %s: %w", sf.Name, err)
+		}
+		for _, opt := range strings.Split(sf.Tag.Get("mcp"), ",") {
+			key, value, _ := strings.Cut(strings.TrimSpace(opt), "=")
+			switch key {
+			case "":
+			case "required":
+				f.required = true
+			case "default":
+				f.defaultVal, f.hasDefault = value, true
+			case "min", "max":
+				v, err := strconv.ParseFloat(value, 64)
+				if err != nil {
+					return nil, fmt.Errorf("field %s: invalid %s %q", sf.Name, key, value)
+				}
+				if key == "min" {
+					f.min = &v
+				} else {
+					f.max = &v
+				}
+			case "enum":
+				f.enum = strings.Split(value, "|")
+			default:
+				return nil, fmt.Errorf("field %s: unknown option %q", sf.Name, key)
+			}
+		}
+		if f.hasDefault {
+			if err := f.set(reflect.New(sf.Type).Elem(), f.defaultVal); err != nil {
+				return nil, fmt.Errorf("field %s: invalid default: %w", sf.Name, err)
+			}
+		}
+		fields = append(fields, f)
+	}
+	return fields, nil
+}
+
+func jsonType(t reflect.Type) (string, error) {
+	switch t.Kind() {
+	case reflect.String:
+		return "string", nil
+	case reflect.Bool:
+		return "boolean", nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return "integer", nil
+	case reflect.Float32, reflect.Float64:
+		return "number", nil
+	case reflect.Slice:
+		if t.Elem().Kind() == reflect.Slice {
+			break
+		}
+		if _, err := jsonType(t.Elem()); err != nil {
+			return "", err
+		}
+		return "array", nil
+	}
+	return "", fmt.Errorf("unsupported type %s", t)
+}
+
+func (f argField) schema() map[string]any {
+	typ, _ := jsonType(f.typ)
+	schema := map[string]any{"type": typ}
+	if typ == "array" {
+		itemType, _ := jsonType(f.typ.Elem())
+		schema["items"] = map[string]any{"type": itemType}
+	}
+	if f.description != "" {
+		schema["description"] = f.description
+	}
+	if f.hasDefault {
+		v := reflect.New(f.typ).Elem()
+		_ = f.set(v, f.defaultVal)
+		schema["default"] = v.Interface()
+	}
+	if f.min != nil {
+		schema["minimum"] = *f.min
+	}
+	if f.max != nil {
+		schema["maximum"] = *f.max
+	}
+	if len(f.enum) > 0 {
+		schema["enum"] = f.enum
+	}
+	return schema
+}
+
+func decodeArgs(fields []argField, arguments map[string]any, args reflect.Value) error {
+	for _, f := range fields {
+		v := args.Field(f.index)
+		raw, ok := arguments[f.name]
+		if !ok || raw == nil {
+			if f.required {
+				return fmt.Errorf("missing required argument %s", f.name)
+			}
+			if f.hasDefault {
+				if err := f.set(v, f.defaultVal); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+		if err := f.set(v, raw); err != nil {
+			return fmt.Errorf("argument %s: %w", f.name, err)
+		}
+		if f.required && v.Kind() == reflect.String && v.String() == "" {
+			return fmt.Errorf("missing required argument %s", f.name)
+		}
+		if err := f.validate(v); err != nil {
+			return fmt.Errorf("argument %s: %w", f.name, err)
+		}
+	}
+	return nil
+}
+
+// set converts raw, a decoded JSON value or a default from the tag, into v.
+func (f argField) set(v reflect.Value, raw any) error {
+	if v.Kind() == reflect.Slice {
+		var items []any
+		switch r := raw.(type) {
+		case []any:
+			items = r
+		case string:
+			// default of a slice is a "|" separated list
+			for _, item := range strings.Split(r, "|") {
+				if item != "" {
+					items = append(items, item)
+				}
+			}
+		default:
+			return fmt.Errorf("expect array, got %T", raw)
+		}
+		slice := reflect.MakeSlice(v.Type(), len(items), len(items))
+		for i, item := range items {
+			if err := setScalar(slice.Index(i), item); err != nil {
+				return fmt.Errorf("item %d: %w", i, err)
+			}
+		}
+		v.Set(slice)
+		return nil
+	}
+	return setScalar(v, raw)
+}
+
+func setScalar(v reflect.Value, raw any) error {
+	switch v.Kind() {
+	case reflect.String:
+		s, ok := raw.(string)
+		if !ok {
+			return fmt.Errorf("expect string, got %T", raw)
+		}
+		v.SetString(s)
+	case reflect.Bool:
+		switch r := raw.(type) {
+		case bool:
+			v.SetBool(r)
+		case string:
+			b, err := strconv.ParseBool(r)
+			if err != nil {
+				return fmt.Errorf("expect boolean, got %q", r)
+			}
+			v.SetBool(b)
+		default:
+			return fmt.Errorf("expect boolean, got %T", raw)
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		// JSON numbers are decoded as float64, some clients also send numbers as strings
+		var n float64
+		switch r := raw.(type) {
+		case float64:
+			n = r
+		case int:
+			n = float64(r)
+		case int64:
+			n = float64(r)
+		case string:
+			var err error
+			if n, err = strconv.ParseFloat(r, 64); err != nil {
+				return fmt.Errorf("expect number, got %q", r)
+			}
+		default:
+			return fmt.Errorf("expect number, got %T", raw)
+		}
+		switch v.Kind() {
+		case reflect.Float32, reflect.Float64:
+			v.SetFloat(n)
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			if n != math.Trunc(n) || n < 0 || v.OverflowUint(uint64(n)) {
+				return fmt.Errorf("expect non-negative integer, got %v", n)
+			}
+			v.SetUint(uint64(n))
+		default:
+			if n != math.Trunc(n) || v.OverflowInt(int64(n)) {
+				return fmt.Errorf("expect integer, got %v", n)
+			}
+			v.SetInt(int64(n))
+		}
+	default:
+		return fmt.Errorf("unsupported type %s", v.Type())
+	}
+	return nil
+}
+
+func (f argField) validate(v reflect.Value) error {
+	values := []reflect.Value{v}
+	if v.Kind() == reflect.Slice {
+		values = values[:0]
+		for i := 0; i < v.Len(); i++ {
+			values = append(values, v.Index(i))
+		}
+	}
+	for _, item := range values {
+		var n float64
+		switch item.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			n = float64(item.Int())
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			n = float64(item.Uint())
+		case reflect.Float32, reflect.Float64:
+			n = item.Float()
+		case reflect.String:
+			if len(f.enum) > 0 && !slices.Contains(f.enum, item.String()) {
+				return fmt.Errorf("%q is not one of %s", item.String(), strings.Join(f.enum, ", "))
+			}
+			continue
+		default:
+			continue
+		}
+		if f.min != nil && n < *f.min {
+			return fmt.Errorf("%v is less than minimum %v", n, *f.min)
+		}
+		if f.max != nil && n > *f.max {
+			return fmt.Errorf("%v is greater than maximum %v", n, *f.max)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,142 @@
+package tools
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+type testArgs struct {
+	Name    string   `json:"name" desc:"名称" mcp:"required"`
+	Lines   int64    `json:"lines" mcp:"default=20,min=1,max=100"`
+	Size    uint     `json:"size"`
+	Ratio   float64  `json:"ratio" mcp:"max=1"`
+	Follow  bool     `json:"follow" mcp:"default=true"`
+	Order   string   `json:"order" mcp:"default=asc,enum=asc|desc"`
+	Ops     []string `json:"ops" mcp:"default=read|write,enum=read|write|flush"`
+	Ports   []int    `json:"ports" mcp:"min=1,max=65535"`
+	Ignored string   `json:"-"`
+	hidden  string
+}
+
+func TestDecodeArgs(t *testing.T) {
+	fields, err := parseArgFields(reflect.TypeFor[testArgs]())
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name      string
+		arguments map[string]any
+		want      testArgs
+		err       string
+	}{
+		{
+			name:      "defaults",
+			arguments: map[string]any{"name": "a"},
+			want:      testArgs{Name: "a", Lines: 20, Follow: true, Order: "asc", Ops: []string{"read", "write"}},
+		},
+		{
+			name: "all set",
+			arguments: map[string]any{
+				"name": "a", "lines": float64(100), "size": float64(3), "ratio": 0.5, "follow": false,
+				"order": "desc", "ops": []any{"flush"}, "ports": []any{float64(80), "443"},
+			},
+			want: testArgs{Name: "a", Lines: 100, Size: 3, Ratio: 0.5, Order: "desc", Ops: []string{"flush"}, Ports: []int{80, 443}},
+		},
+		{
+			name:      "numbers and bools as strings",
+			arguments: map[string]any{"name": "a", "lines": "5", "follow": "false"},
+			want:      testArgs{Name: "a", Lines: 5, Order: "asc", Ops: []string{"read", "write"}},
+		},
+		{
+			name:      "null is missing",
+			arguments: map[string]any{"name": "a", "lines": nil},
+			want:      testArgs{Name: "a", Lines: 20, Follow: true, Order: "asc", Ops: []string{"read", "write"}},
+		},
+		{name: "missing required", arguments: map[string]any{}, err: "missing required argument name"},
+		{name: "empty required", arguments: map[string]any{"name": ""}, err: "missing required argument name"},
+		{name: "wrong type", arguments: map[string]any{"name": float64(1)}, err: "argument name: expect string"},
+		{name: "below min", arguments: map[string]any{"name": "a", "lines": float64(0)}, err: "less than minimum"},
+		{name: "above max", arguments: map[string]any{"name": "a", "ratio": 1.5}, err: "greater than maximum"},
+		{name: "fraction", arguments: map[string]any{"name": "a", "lines": 1.5}, err: "expect integer"},
+		{name: "negative uint", arguments: map[string]any{"name": "a", "size": float64(-1)}, err: "expect non-negative integer"},
+		{name: "not in enum", arguments: map[string]any{"name": "a", "order": "up"}, err: `"up" is not one of asc, desc`},
+		{name: "item not in enum", arguments: map[string]any{"name": "a", "ops": []any{"read", "open"}}, err: `"open" is not one of`},
+		{name: "item above max", arguments: map[string]any{"name": "a", "ports": []any{float64(70000)}}, err: "greater than maximum"},
+		{name: "not an array", arguments: map[string]any{"name": "a", "ports": float64(80)}, err: "expect array"},
+		{name: "invalid bool", arguments: map[string]any{"name": "a", "follow": "maybe"}, err: "expect boolean"},
+	}
+	for _, tt := range tests {
+		var args testArgs
+		err := decodeArgs(fields, tt.arguments, reflect.ValueOf(&args).Elem())
+		if tt.err != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("%s: error %v, want %q", tt.name, err, tt.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(args, tt.want) {
+			t.Errorf("%s: got %+v, want %+v", tt.name, args, tt.want)
+		}
+	}
+}
+
+func TestTypedToolSchema(t *testing.T) {
+	tool := NewTypedTool("test", "测试", func(ctx context.Context, request mcp.CallToolRequest, args testArgs) (*mcp.CallToolResult, error) {
+		return mcp.NewToolResultText(args.Name), nil
+	})
+	schema := tool.Tool.InputSchema
+	if !reflect.DeepEqual(schema.Required, []string{"name"}) {
+		t.Errorf("required = %v", schema.Required)
+	}
+	if len(schema.Properties) != 8 {
+		t.Errorf("got %d properties, want 8: %v", len(schema.Properties), schema.Properties)
+	}
+	want := map[string]map[string]any{
+		"name":  {"type": "string", "description": "名称"},
+		"lines": {"type": "integer", "default": int64(20), "minimum": float64(1), "maximum": float64(100)},
+		"order": {"type": "string", "default": "asc", "enum": []string{"asc", "desc"}},
+		"ops": {"type": "array", "items": map[string]any{"type": "string"}, "default": []string{"read", "write"},
+			"enum": []string{"read", "write", "flush"}},
+	}
+	for name, w := range want {
+		if got := schema.Properties[name]; !reflect.DeepEqual(got, w) {
+			t.Errorf("schema of %s = %v, want %v", name, got, w)
+		}
+	}
+
+	request := mcp.CallToolRequest{}
+	request.Params.Arguments = map[string]any{"lines": float64(1000)}
+	result, err := tool.Handler(context.Background(), request)
+	if err != nil || !result.IsError {
+		t.Errorf("invalid arguments not returned as a tool error: %v %+v", err, result)
+	}
+}
+
+func TestParseArgFieldsInvalid(t *testing.T) {
+	for name, typ := range map[string]reflect.Type{
+		"not a struct": reflect.TypeFor[string](),
+		"map field":    reflect.TypeFor[struct{ M map[string]string }](),
+		"nested slice": reflect.TypeFor[struct{ S [][]int }](),
+		"unknown option": reflect.TypeFor[struct {
+			S string `mcp:"optional"`
+		}](),
+		"invalid min": reflect.TypeFor[struct {
+			N int `mcp:"min=a"`
+		}](),
+		"invalid default": reflect.TypeFor[struct {
+			N int `mcp:"default=x"`
+		}](),
+	} {
+		if _, err := parseArgFields(typ); err == nil {
+			t.Errorf("%s: parsed", name)
+		}
+	}
+}
@@ -0,0 +1,98 @@
+package tools
+
+import (
+	"context"
+	"sync"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// MethodNotificationCancelled is sent by a client to cancel one of its requests.
+const MethodNotificationCancelled = "notifications/cancelled"
+
+// requestIDField is the meta field carrying the request ID from the hook to the middleware,
+// mcp-go passes the ID of a request to hooks but not to tool handlers.
+const requestIDField = "juicefs-mcp/requestId"
+
+type inflightKey struct {
+	session   string
+	requestID string
+}
+
+// Canceller cancels the context of a tool call when its client sends notifications/cancelled for it.
+// Under stdio transport mcp-go handles messages one by one, so the notification only arrives after
+// the call has finished.
+type Canceller struct {
+	mu       sync.Mutex
+	inflight map[inflightKey]context.CancelFunc
+}
+
+func NewCanceller() *Canceller {
+	return &Canceller{inflight: map[inflightKey]context.CancelFunc{}}
+}
+
+// Hooks returns the hooks tagging every tool call with its request ID, to be given to the server
+// together with the middleware and the notification handler.
+func (c *Canceller) Hooks() *server.Hooks {
+	hooks := &server.Hooks{}
+	hooks.AddBeforeCallTool(func(ctx context.Context, id any, request *mcp.CallToolRequest) {
+		if request.Params.Meta == nil {
+			request.Params.Meta = &mcp.Meta{}
+		}
+		if request.Params.Meta.AdditionalFields == nil {
+			request.Params.Meta.AdditionalFields = map[string]any{}
+		}
+		// set even if the client sent it, it can't pick the ID
+		request.Params.Meta.AdditionalFields[requestIDField] = mcp.NewRequestId(id).String()
+	})
+	return hooks
+}
+
+func sessionID(ctx context.Context) string {
+	if session := server.ClientSessionFromContext(ctx); session != nil {
+		return session.SessionID()
+	}
+	return ""
+}
+
+func (c *Canceller) Middleware(next server.ToolHandlerFunc) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		if request.Params.Meta == nil {
+			return next(ctx, request)
+		}
+		requestID, ok := request.Params.Meta.AdditionalFields[requestIDField].(string)
+		if !ok {
+			return next(ctx, request)
+		}
+		delete(request.Params.Meta.AdditionalFields, requestIDField)
+
+		key := inflightKey{session: sessionID(ctx), requestID: requestID}
+		ctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+		c.mu.Lock()
+		c.inflight[key] = cancel
+		c.mu.Unlock()
+		defer func() {
+			c.mu.Lock()
+			delete(c.inflight, key)
+			c.mu.Unlock()
+		}()
+		return next(ctx, request)
+	}
+}
+
+// HandleCancelled handles notifications/cancelled, a client can only cancel its own requests.
+func (c *Canceller) HandleCancelled(ctx context.Context, notification mcp.JSONRPCNotification) {
+	id, ok := notification.Params.AdditionalFields["requestId"]
+	if !ok {
+		return
+	}
+	key := inflightKey{session: sessionID(ctx), requestID: mcp.NewRequestId(id).String()}
+	c.mu.Lock()
+	cancel, ok := c.inflight[key]
+	c.mu.Unlock()
+	if ok {
+		cancel()
+	}
+}
@@ -0,0 +1,52 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+func TestCancellerCancelsToolCall(t *testing.T) {
+	c := NewCanceller()
+	s := server.NewMCPServer("test", "0", server.WithHooks(c.Hooks()), server.WithToolHandlerMiddleware(c.Middleware))
+	s.AddNotificationHandler(MethodNotificationCancelled, c.HandleCancelled)
+	started := make(chan struct{})
+	s.AddTool(mcp.NewTool("wait"), func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		if request.Params.Meta != nil && request.Params.Meta.AdditionalFields[requestIDField] != nil {
+			t.Error("request ID field passed to the handler")
+		}
+		close(started)
+		select {
+		case <-ctx.Done():
+			return mcp.NewToolResultText("cancelled"), nil
+		case <-time.After(5 * time.Second):
+			return mcp.NewToolResultText("finished"), nil
+		}
+	})
+
+	ctx := context.Background()
+	done := make(chan mcp.JSONRPCMessage)
+	go func() {
+		done <- s.HandleMessage(ctx, []byte(`{"jsonrpc":"2.0","id":7,"method":"tools/call","params":{"name":"wait"}}`))
+	}()
+	<-started
+	// another request ID doesn't cancel it
+	s.HandleMessage(ctx, []byte(`{"jsonrpc":"2.0","method":"notifications/cancelled","params":{"requestId":8}}`))
+	s.HandleMessage(ctx, []byte(`{"jsonrpc":"2.0","method":"notifications/cancelled","params":{"requestId":7,"reason":"user"}}`))
+	select {
+	case response := <-done:
+		if got := fmt.Sprint(response); !strings.Contains(got, "cancelled") {
+			t.Errorf("tool call not cancelled: %s", got)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatal("tool call not cancelled")
+	}
+	if len(c.inflight) != 0 {
+		t.Errorf("%d calls left in flight", len(c.inflight))
+	}
+}
@@ -0,0 +1,121 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"time"
+	"unicode/utf8"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// Limiter bounds the run time and the output size of tool calls, the limits can be changed at runtime.
+type Limiter struct {
+	maxOutputBytes atomic.Int64
+	timeout        atomic.Int64
+}
+
+func NewLimiter(maxOutputBytes int, timeout time.Duration) *Limiter {
+	l := &Limiter{}
+	l.SetLimits(maxOutputBytes, timeout)
+	return l
+}
+
+// SetLimits sets the limits, 0 means unlimited.
+func (l *Limiter) SetLimits(maxOutputBytes int, timeout time.Duration) {
+	l.maxOutputBytes.Store(int64(maxOutputBytes))
+	l.timeout.Store(int64(timeout))
+}
+
+// toolTimeout returns the timeout of a tool call. Tools declaring their own timeout in seconds in
+// their input schema, e.g. bench and warmup, may run for as long as they ask if it's longer than the
+// limit, up to the maximum of the schema.
+func (l *Limiter) toolTimeout(request mcp.CallToolRequest) time.Duration {
+	timeout := time.Duration(l.timeout.Load())
+	if timeout <= 0 {
+		return 0
+	}
+	seconds, ok := request.GetArguments()["timeout"].(float64)
+	if !ok {
+		return timeout
+	}
+	tool, ok := GetTool(request.Params.Name)
+	if !ok {
+		return timeout
+	}
+	schema, ok := tool.Tool.InputSchema.Properties["timeout"].(map[string]any)
+	if !ok {
+		return timeout
+	}
+	if maximum, ok := schema["maximum"].(float64); ok {
+		seconds = min(seconds, maximum)
+	}
+	if own := time.Duration(seconds * float64(time.Second)); own > timeout {
+		return own
+	}
+	return timeout
+}
+
+func (l *Limiter) Middleware(next server.ToolHandlerFunc) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		limitCtx, timeout := ctx, l.toolTimeout(request)
+		if timeout > 0 {
+			var cancel context.CancelFunc
+			limitCtx, cancel = context.WithTimeout(ctx, timeout)
+			defer cancel()
+		}
+		result, err := next(limitCtx, request)
+		// the handler only sees its context done, the limit that stopped it is reported here
+		if timeout > 0 && ctx.Err() == nil && errors.Is(limitCtx.Err(), context.DeadlineExceeded) {
+			return nil, fmt.Errorf("tool %s not finished in %s, the tool timeout of the server", request.Params.Name, timeout)
+		}
+		if err != nil || result == nil {
+			return result, err
+		}
+		if limit := int(l.maxOutputBytes.Load()); limit > 0 {
+			truncateResult(result, limit)
+		}
+		return result, nil
+	}
+}
+
+// TruncatedText is what a text content over the output limit is replaced with, so that the result
+// is still valid JSON. Text is the beginning of the original text.
+type TruncatedText struct {
+	Truncated     bool   `json:"truncated"`
+	OriginalBytes int    `json:"originalBytes"`
+	LimitBytes    int    `json:"limitBytes"`
+	Text          string `json:"text"`
+}
+
+// truncateResult truncates the text contents of result to about limit bytes in total.
+func truncateResult(result *mcp.CallToolResult, limit int) {
+	remain := limit
+	for i, content := range result.Content {
+		text, ok := content.(mcp.TextContent)
+		if !ok {
+			continue
+		}
+		if len(text.Text) <= remain {
+			remain -= len(text.Text)
+			continue
+		}
+		cut := remain
+		for cut > 0 && !utf8.RuneStart(text.Text[cut]) {
+			cut--
+		}
+		envelope, _ := json.Marshal(TruncatedText{
+			Truncated:     true,
+			OriginalBytes: len(text.Text),
+			LimitBytes:    limit,
+			Text:          text.Text[:cut],
+		})
+		text.Text = string(envelope)
+		result.Content[i] = text
+		remain = 0
+	}
+}
@@ -0,0 +1,88 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+func TestTruncateResult(t *testing.T) {
+	long := `{"rows":[` + strings.Repeat(`"行",`, 100) + `"end"]}`
+	result := mcp.NewToolResultText(long)
+	truncateResult(result, 50)
+	text := result.Content[0].(mcp.TextContent).Text
+	truncated := TruncatedText{}
+	if err := json.Unmarshal([]byte(text), &truncated); err != nil {
+		t.Fatalf("truncated result is not JSON: %v: %s", err, text)
+	}
+	if !truncated.Truncated || truncated.OriginalBytes != len(long) || truncated.LimitBytes != 50 {
+		t.Errorf("unexpected envelope %+v", truncated)
+	}
+	if len(truncated.Text) > 50 || !strings.HasPrefix(long, truncated.Text) {
+		t.Errorf("text %q is not a prefix of at most 50 bytes", truncated.Text)
+	}
+
+	short := mcp.NewToolResultText(`{"ok":true}`)
+	truncateResult(short, 50)
+	if got := short.Content[0].(mcp.TextContent).Text; got != `{"ok":true}` {
+		t.Errorf("short result changed to %s", got)
+	}
+}
+
+func TestToolTimeout(t *testing.T) {
+	type benchArgs struct {
+		Timeout int `json:"timeout" mcp:"default=0,min=0,max=7200"`
+	}
+	type statusArgs struct {
+		Mountpoint string `json:"mountpoint"`
+	}
+	RegistryTool("limit", TierReadOnly, NewTypedTool("bench", "", func(ctx context.Context, request mcp.CallToolRequest, args benchArgs) (*mcp.CallToolResult, error) {
+		return nil, nil
+	}))
+	RegistryTool("limit", TierReadOnly, NewTypedTool("status", "", func(ctx context.Context, request mcp.CallToolRequest, args statusArgs) (*mcp.CallToolResult, error) {
+		return nil, nil
+	}))
+
+	l := NewLimiter(0, time.Minute)
+	tests := []struct {
+		tool string
+		args map[string]any
+		want time.Duration
+	}{
+		{"limit_bench", nil, time.Minute},
+		{"limit_bench", map[string]any{"timeout": float64(0)}, time.Minute},
+		{"limit_bench", map[string]any{"timeout": float64(30)}, time.Minute},
+		{"limit_bench", map[string]any{"timeout": float64(3600)}, time.Hour},
+		// capped at the maximum of the schema
+		{"limit_bench", map[string]any{"timeout": float64(86400)}, 2 * time.Hour},
+		// a tool without a timeout in its schema can't extend the limit
+		{"limit_status", map[string]any{"timeout": float64(3600)}, time.Minute},
+		{"limit_unknown", map[string]any{"timeout": float64(3600)}, time.Minute},
+	}
+	for _, tt := range tests {
+		request := mcp.CallToolRequest{}
+		request.Params.Name = tt.tool
+		request.Params.Arguments = tt.args
+		if got := l.toolTimeout(request); got != tt.want {
+			t.Errorf("toolTimeout(%s, %v) = %s, want %s", tt.tool, tt.args, got, tt.want)
+		}
+	}
+}
+
+func TestMiddlewareReportsTimeout(t *testing.T) {
+	l := NewLimiter(0, 10*time.Millisecond)
+	handler := l.Middleware(func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	})
+	request := mcp.CallToolRequest{}
+	request.Params.Name = "slow"
+	_, err := handler(context.Background(), request)
+	if err == nil || !strings.Contains(err.Error(), "10ms") {
+		t.Errorf("error = %v, want the tool timeout of the server", err)
+	}
+}
@@ -0,0 +1,114 @@
+package tools
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/fs"
+	"path"
+	"strings"
+	"text/template"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"gopkg.in/yaml.v3"
+)
+
+var PromptRegistry = []server.ServerPrompt{}
+
+// promptMeta is the YAML front matter of a prompt file.
+type promptMeta struct {
+	Name        string `yaml:"name"`
+	Description string `yaml:"description"`
+	Arguments   []struct {
+		Name        string `yaml:"name"`
+		Description string `yaml:"description"`
+		Required    bool   `yaml:"required"`
+	} `yaml:"arguments"`
+}
+
+// RegistryPrompts registers every "*.md" prompt file in dir of fsys under namespace.
+// A prompt file starts with a YAML front matter of its name, description and arguments,
+// followed by a text/template body the arguments are interpolated into, e.g.
+//
+//	---
+//	name: expert
+//	description: JuiceFS 专家
+//	arguments:
+//	  - name: mountpoint
+//	    description: 挂载点
+//	---
+//	你是一名 JuiceFS 专家。{{if .mountpoint}}需要排查的挂载点是 {{.mountpoint}}。{{end}}
+func RegistryPrompts(namespace string, fsys fs.FS, dir string) error {
+	files, err := fs.Glob(fsys, path.Join(dir, "*.md"))
+	if err != nil {
+		return err
+	}
+	for _, file := range files {
+		data, err := fs.ReadFile(fsys, file)
+		if err != nil {
+			return err
+		}
+		prompt, err := parsePrompt(namespace, data)
+		if err != nil {
+			return fmt.Errorf("parse prompt %s error: %w", file, err)
+		}
+		lock.Lock()
+		PromptRegistry = append(PromptRegistry, prompt)
+		lock.Unlock()
+	}
+	return nil
+}
+
+func parsePrompt(namespace string, data []byte) (server.ServerPrompt, error) {
+	content, ok := strings.CutPrefix(string(data), "---\n")
+	if !ok {
+		return server.ServerPrompt{}, fmt.Errorf("missing front matter")
+	}
+	front, body, ok := strings.Cut(content, "\n---\n")
+	if !ok {
+		return server.ServerPrompt{}, fmt.Errorf("unterminated front matter")
+	}
+	meta := promptMeta{}
+	if err := yaml.Unmarshal([]byte(front), &meta); err != nil {
+		return server.ServerPrompt{}, err
+	}
+	if meta.Name == "" {
+		return server.ServerPrompt{}, fmt.Errorf("missing name")
+	}
+	tmpl, err := template.New(meta.Name).Option("missingkey=zero").Parse(strings.TrimSpace(body))
+	if err != nil {
+		return server.ServerPrompt{}, err
+	}
+
+	opts := []mcp.PromptOption{mcp.WithPromptDescription(meta.Description)}
+	for _, arg := range meta.Arguments {
+		argOpts := []mcp.ArgumentOption{mcp.ArgumentDescription(arg.Description)}
+		if arg.Required {
+			argOpts = append(argOpts, mcp.RequiredArgument())
+		}
+		opts = append(opts, mcp.WithArgument(arg.Name, argOpts...))
+	}
+	prompt := mcp.NewPrompt(ToolName(namespace, meta.Name), opts...)
+
+	return server.ServerPrompt{
+		Prompt: prompt,
+		Handler: func(ctx context.Context, request mcp.GetPromptRequest) (*mcp.GetPromptResult, error) {
+			args := map[string]string{}
+			for _, arg := range meta.Arguments {
+				value := request.Params.Arguments[arg.Name]
+				if arg.Required && value == "" {
+					return nil, fmt.Errorf("missing required argument %s", arg.Name)
+				}
+				args[arg.Name] = value
+			}
+			buf := &bytes.Buffer{}
+			if err := tmpl.Execute(buf, args); err != nil {
+				return nil, fmt.Errorf("render prompt %s error: %w", prompt.Name, err)
+			}
+			return mcp.NewGetPromptResult(meta.Description, []mcp.PromptMessage{
+				mcp.NewPromptMessage(mcp.RoleUser, mcp.NewTextContent(buf.String())),
+			}), nil
+		},
+	}, nil
+}
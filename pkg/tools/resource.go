@@ -0,0 +1,29 @@
+package tools
+
+import (
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// ResourceTemplate is a resource template with its handler.
+type ResourceTemplate struct {
+	Template mcp.ResourceTemplate
+	Handler  server.ResourceTemplateHandlerFunc
+}
+
+var (
+	ResourceRegistry         = []server.ServerResource{}
+	ResourceTemplateRegistry = []ResourceTemplate{}
+)
+
+func RegistryResource(resource server.ServerResource) {
+	lock.Lock()
+	ResourceRegistry = append(ResourceRegistry, resource)
+	lock.Unlock()
+}
+
+func RegistryResourceTemplate(template ResourceTemplate) {
+	lock.Lock()
+	ResourceTemplateRegistry = append(ResourceTemplateRegistry, template)
+	lock.Unlock()
+}
@@ -6,13 +6,57 @@ import (
 	"github.com/mark3labs/mcp-go/server"
 )
 
+// Tier classifies what a tool can do, authorization policies grant tools by tier.
+type Tier string
+
+const (
+	// TierReadOnly tools only read non-sensitive state, e.g. pod status.
+	TierReadOnly Tier = "read-only"
+	// TierSensitiveRead tools read state that may contain secrets or private data, e.g. logs and mount options.
+	TierSensitiveRead Tier = "sensitive-read"
+	// TierMutating tools change the state of the cluster or the filesystem.
+	TierMutating Tier = "mutating"
+)
+
+// Tool is a server tool with its classification.
+type Tool struct {
+	server.ServerTool
+	Tier Tier
+}
+
 var (
-	ToolRegistry = []server.ServerTool{}
+	ToolRegistry = []Tool{}
 	lock         = &sync.Mutex{}
 )
 
-func RegistryTool(tool server.ServerTool) {
+// ToolName returns the name of tool in the toolset of namespace, e.g. "csi_get_pod".
+func ToolName(namespace, name string) string {
+	if namespace == "" {
+		return name
+	}
+	return namespace + "_" + name
+}
+
+// RegistryTool registers tool of tier under namespace, so that toolsets of different handlers
+// never collide when they are served by the same server.
+func RegistryTool(namespace string, tier Tier, tool server.ServerTool) {
+	tool.Tool.Name = ToolName(namespace, tool.Tool.Name)
+	readOnly, destructive := tier != TierMutating, tier == TierMutating
+	tool.Tool.Annotations.ReadOnlyHint = &readOnly
+	tool.Tool.Annotations.DestructiveHint = &destructive
 	lock.Lock()
-	ToolRegistry = append(ToolRegistry, tool)
+	ToolRegistry = append(ToolRegistry, Tool{ServerTool: tool, Tier: tier})
 	lock.Unlock()
 }
+
+// GetTool returns the registered tool of name.
+func GetTool(name string) (Tool, bool) {
+	lock.Lock()
+	defer lock.Unlock()
+	for _, tool := range ToolRegistry {
+		if tool.Tool.Name == name {
+			return tool, true
+		}
+	}
+	return Tool{}, false
+}
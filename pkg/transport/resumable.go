@@ -0,0 +1,266 @@
+package transport
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const (
+	headerKeySessionID   = "Mcp-Session-Id"
+	headerKeyLastEventID = "Last-Event-ID"
+
+	// maxSessions bounds the number of sessions whose history is kept,
+	// the oldest session is dropped first.
+	maxSessions = 1024
+	// maxStreams bounds the number of streams kept per session, the oldest stream is dropped first.
+	maxStreams = 64
+)
+
+// ResumableHandler makes the SSE streams of a streamable HTTP handler resumable.
+// Every SSE response of a session is a stream of its own, each event written to it gets an id
+// "<stream>-<seq>" and is kept in a bounded per-stream history. A client reconnecting with
+// Last-Event-ID gets the events it missed on that stream replayed before the new stream
+// continues as the same stream. Pings are neither numbered nor kept.
+type ResumableHandler struct {
+	next     http.Handler
+	size     int
+	lock     sync.Mutex
+	history  map[string]*sessionHistory
+	sessions []string
+}
+
+type sessionHistory struct {
+	lastStream int64
+	streams    map[int64]*streamHistory
+	order      []int64
+}
+
+type streamHistory struct {
+	lastSeq int64
+	events  []sseEvent
+}
+
+type sseEvent struct {
+	seq  int64
+	data []byte
+}
+
+func NewResumableHandler(next http.Handler, size int) *ResumableHandler {
+	return &ResumableHandler{
+		next:    next,
+		size:    size,
+		history: map[string]*sessionHistory{},
+	}
+}
+
+func (h *ResumableHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	sessionID := r.Header.Get(headerKeySessionID)
+	if sessionID == "" {
+		h.next.ServeHTTP(w, r)
+		return
+	}
+	if r.Method == http.MethodDelete {
+		h.lock.Lock()
+		h.forget(sessionID)
+		h.lock.Unlock()
+		h.next.ServeHTTP(w, r)
+		return
+	}
+
+	rw := &resumableWriter{ResponseWriter: w, handler: h, sessionID: sessionID}
+	if r.Method == http.MethodGet {
+		if streamID, seq, ok := parseEventID(r.Header.Get(headerKeyLastEventID)); ok {
+			rw.streamID, rw.seq, rw.replay = h.eventsAfter(sessionID, streamID, seq)
+		}
+	}
+	h.next.ServeHTTP(rw, r)
+}
+
+func formatEventID(streamID, seq int64) string {
+	return fmt.Sprintf("%d-%d", streamID, seq)
+}
+
+func parseEventID(id string) (streamID, seq int64, ok bool) {
+	stream, n, found := strings.Cut(id, "-")
+	if !found {
+		return 0, 0, false
+	}
+	streamID, err := strconv.ParseInt(stream, 10, 64)
+	if err != nil {
+		return 0, 0, false
+	}
+	seq, err = strconv.ParseInt(n, 10, 64)
+	if err != nil {
+		return 0, 0, false
+	}
+	return streamID, seq, true
+}
+
+// session returns the history of session, the caller must hold the lock.
+func (h *ResumableHandler) session(sessionID string) *sessionHistory {
+	hist, ok := h.history[sessionID]
+	if !ok {
+		hist = &sessionHistory{streams: map[int64]*streamHistory{}}
+		h.history[sessionID] = hist
+		h.sessions = append(h.sessions, sessionID)
+		if len(h.sessions) > maxSessions {
+			h.forget(h.sessions[0])
+		}
+	}
+	return hist
+}
+
+// newStream starts a stream of session and returns its id.
+func (h *ResumableHandler) newStream(sessionID string) int64 {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	hist := h.session(sessionID)
+	hist.lastStream++
+	hist.streams[hist.lastStream] = &streamHistory{}
+	hist.order = append(hist.order, hist.lastStream)
+	if len(hist.order) > maxStreams {
+		delete(hist.streams, hist.order[0])
+		hist.order = hist.order[1:]
+	}
+	return hist.lastStream
+}
+
+// record keeps the event of seq in the history of the stream.
+func (h *ResumableHandler) record(sessionID string, streamID, seq int64, data []byte) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	stream, ok := h.session(sessionID).streams[streamID]
+	if !ok {
+		// the stream was dropped while it's still open, its events are numbered but not kept
+		return
+	}
+	stream.lastSeq = seq
+	stream.events = append(stream.events, sseEvent{seq: seq, data: data})
+	if len(stream.events) > h.size {
+		stream.events = stream.events[len(stream.events)-h.size:]
+	}
+}
+
+// forget drops the history of session, the caller must hold the lock.
+func (h *ResumableHandler) forget(sessionID string) {
+	delete(h.history, sessionID)
+	for i, id := range h.sessions {
+		if id == sessionID {
+			h.sessions = append(h.sessions[:i], h.sessions[i+1:]...)
+			break
+		}
+	}
+}
+
+// eventsAfter returns the events of the stream after seq, and the stream id and its last sequence
+// to continue with, 0 if the stream isn't known and a new one should be started.
+func (h *ResumableHandler) eventsAfter(sessionID string, streamID, seq int64) (int64, int64, []sseEvent) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	hist, ok := h.history[sessionID]
+	if !ok {
+		return 0, 0, nil
+	}
+	stream, ok := hist.streams[streamID]
+	if !ok {
+		return 0, 0, nil
+	}
+	events := []sseEvent{}
+	for _, e := range stream.events {
+		if e.seq > seq {
+			events = append(events, e)
+		}
+	}
+	return streamID, stream.lastSeq, events
+}
+
+// isPing returns whether an SSE event is a keep-alive, a comment or a ping request.
+func isPing(event []byte) bool {
+	for _, line := range bytes.Split(event, []byte("\n")) {
+		if data, ok := bytes.CutPrefix(line, []byte("data:")); ok {
+			msg := struct {
+				Method string `json:"method"`
+			}{}
+			return json.Unmarshal(bytes.TrimSpace(data), &msg) == nil && msg.Method == "ping"
+		}
+	}
+	// an event without data is a comment
+	return true
+}
+
+// resumableWriter tags the SSE events written by the handler with ids,
+// and replays missed events once the stream is established.
+type resumableWriter struct {
+	http.ResponseWriter
+	handler   *ResumableHandler
+	sessionID string
+	streamID  int64
+	// seq is the last sequence of the stream, it's counted here so that it outlives the history
+	// of the stream, which may be dropped while the stream is open
+	seq         int64
+	replay      []sseEvent
+	wroteHeader bool
+	isStream    bool
+	pending     []byte
+}
+
+func (w *resumableWriter) WriteHeader(statusCode int) {
+	if w.wroteHeader {
+		return
+	}
+	w.wroteHeader = true
+	w.isStream = strings.HasPrefix(w.Header().Get("Content-Type"), "text/event-stream")
+	w.ResponseWriter.WriteHeader(statusCode)
+	if !w.isStream {
+		return
+	}
+	if w.streamID == 0 {
+		w.streamID = w.handler.newStream(w.sessionID)
+	}
+	for _, e := range w.replay {
+		_, _ = fmt.Fprintf(w.ResponseWriter, "id: %s\n%s\n\n", formatEventID(w.streamID, e.seq), e.data)
+	}
+	w.replay = nil
+	w.Flush()
+}
+
+func (w *resumableWriter) Write(p []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	if !w.isStream {
+		return w.ResponseWriter.Write(p)
+	}
+	w.pending = append(w.pending, p...)
+	for {
+		idx := bytes.Index(w.pending, []byte("\n\n"))
+		if idx < 0 {
+			break
+		}
+		data := append([]byte{}, w.pending[:idx]...)
+		w.pending = w.pending[idx+2:]
+		if isPing(data) {
+			if _, err := fmt.Fprintf(w.ResponseWriter, "%s\n\n", data); err != nil {
+				return 0, err
+			}
+			continue
+		}
+		w.seq++
+		w.handler.record(w.sessionID, w.streamID, w.seq, data)
+		if _, err := fmt.Fprintf(w.ResponseWriter, "id: %s\n%s\n\n", formatEventID(w.streamID, w.seq), data); err != nil {
+			return 0, err
+		}
+	}
+	return len(p), nil
+}
+
+func (w *resumableWriter) Flush() {
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
@@ -0,0 +1,113 @@
+package transport
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// sseHandler writes the events of the query param "events", separated by commas, as an SSE stream.
+func sseHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.WriteHeader(http.StatusOK)
+		for _, event := range strings.Split(r.URL.Query().Get("events"), ",") {
+			if event == "" {
+				continue
+			}
+			method := "notifications/message"
+			if event == "ping" {
+				method = "ping"
+			}
+			fmt.Fprintf(w, "event: message\ndata: {\"jsonrpc\":\"2.0\",\"method\":%q,\"params\":{\"n\":%q}}\n\n", method, event)
+		}
+	})
+}
+
+func serve(h http.Handler, method, events, lastEventID string) string {
+	r := httptest.NewRequest(method, "/mcp?events="+events, nil)
+	r.Header.Set(headerKeySessionID, "s1")
+	if lastEventID != "" {
+		r.Header.Set(headerKeyLastEventID, lastEventID)
+	}
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	return w.Body.String()
+}
+
+func eventIDs(body string) []string {
+	ids := []string{}
+	for _, line := range strings.Split(body, "\n") {
+		if id, ok := strings.CutPrefix(line, "id: "); ok {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+func TestResumableStreams(t *testing.T) {
+	h := NewResumableHandler(sseHandler(), 100)
+
+	post := serve(h, http.MethodPost, "a,ping,b", "")
+	if ids := eventIDs(post); strings.Join(ids, " ") != "1-1 1-2" {
+		t.Fatalf("POST stream ids = %v, want 1-1 1-2 with the ping not numbered", ids)
+	}
+	if !strings.Contains(post, `"method":"ping"`) {
+		t.Error("ping not passed through")
+	}
+	get := serve(h, http.MethodGet, "c,d", "")
+	if ids := eventIDs(get); strings.Join(ids, " ") != "2-1 2-2" {
+		t.Fatalf("GET stream ids = %v, want 2-1 2-2", ids)
+	}
+
+	// resuming the GET stream replays its own missed events only, then continues it
+	resumed := serve(h, http.MethodGet, "e", "2-1")
+	if ids := eventIDs(resumed); strings.Join(ids, " ") != "2-2 2-3" {
+		t.Errorf("resumed stream ids = %v, want 2-2 2-3", ids)
+	}
+	if strings.Contains(resumed, `"n":"a"`) || strings.Contains(resumed, `"n":"b"`) {
+		t.Errorf("events of the POST stream replayed on the GET stream: %s", resumed)
+	}
+
+	// an unknown stream starts a new one without replay
+	fresh := serve(h, http.MethodGet, "f", "9-1")
+	if ids := eventIDs(fresh); strings.Join(ids, " ") != "3-1" {
+		t.Errorf("fresh stream ids = %v, want 3-1", ids)
+	}
+}
+
+func TestResumableHistorySize(t *testing.T) {
+	h := NewResumableHandler(sseHandler(), 2)
+	serve(h, http.MethodGet, "a,b,c,ping,ping,ping", "")
+	resumed := serve(h, http.MethodGet, "", "1-0")
+	if ids := eventIDs(resumed); strings.Join(ids, " ") != "1-2 1-3" {
+		t.Errorf("replayed ids = %v, want the last 2 events 1-2 1-3", ids)
+	}
+}
+
+func TestResumableDroppedStream(t *testing.T) {
+	var h *ResumableHandler
+	h = NewResumableHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		for i := 0; i < 3; i++ {
+			fmt.Fprintf(w, "event: message\ndata: {\"n\":%d}\n\n", i)
+			// newer streams drop the history of this one while it's still open
+			for j := 0; j < maxStreams; j++ {
+				h.newStream("s1")
+			}
+		}
+	}), 100)
+	if ids := eventIDs(serve(h, http.MethodGet, "", "")); strings.Join(ids, " ") != "1-1 1-2 1-3" {
+		t.Errorf("ids of the dropped stream = %v, want 1-1 1-2 1-3", ids)
+	}
+}
+
+func TestParseEventID(t *testing.T) {
+	for id, want := range map[string]bool{"1-2": true, "12": false, "a-1": false, "1-b": false, "": false} {
+		if _, _, ok := parseEventID(id); ok != want {
+			t.Errorf("parseEventID(%q) ok = %v, want %v", id, ok, want)
+		}
+	}
+}
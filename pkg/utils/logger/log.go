@@ -44,6 +44,11 @@ func SetDebug(enable bool) {
 	atom.SetLevel(zap.InfoLevel)
 }
 
+// SetLevel sets the log level by name, e.g. debug, info, warn.
+func SetLevel(level string) error {
+	return atom.UnmarshalText([]byte(level))
+}
+
 func CostLog(logger *zap.SugaredLogger, msg string) func() {
 	startAt := time.Now()
 	logger.Infof("%s start", msg)